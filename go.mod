@@ -5,12 +5,7 @@ go 1.23.6
 exclude github.com/qiniu/iconv v1.2.0
 
 require (
-	github.com/cloudinn/escpos v0.0.0-20250812201354-aba1caa15544
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/mattn/go-sqlite3 v1.14.32
-)
-
-require (
-	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
-	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646 // indirect
-	golang.org/x/image v0.0.0-20190729225735-1bd0cf576493 // indirect
+	golang.org/x/image v0.0.0-20190729225735-1bd0cf576493
 )
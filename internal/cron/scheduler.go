@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -79,9 +82,93 @@ func (s *Scheduler) executePrintJob() {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		log.Printf("Print job completed successfully (status: %d)", resp.StatusCode)
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusAccepted {
+		log.Printf("Print jobs queued successfully (status: %d)", resp.StatusCode)
 	} else {
 		log.Printf("Print job failed with status: %d", resp.StatusCode)
 	}
 }
+
+// StartWeeklyReportJob starts a goroutine that calls print-report every week on the day and
+// hour set by WEEKLY_REPORT_DAY (e.g. Sunday) and WEEKLY_REPORT_HOUR (default 18), Montreal time.
+// It returns false when WEEKLY_REPORT_DAY is not set.
+func (s *Scheduler) StartWeeklyReportJob() bool {
+	dayName := strings.TrimSpace(os.Getenv("WEEKLY_REPORT_DAY"))
+	if dayName == "" {
+		return false
+	}
+
+	weekday, ok := parseWeekday(dayName)
+	if !ok {
+		log.Printf("Invalid WEEKLY_REPORT_DAY %q, the weekly report is not scheduled", dayName)
+		return false
+	}
+
+	hour := 18
+	if raw := strings.TrimSpace(os.Getenv("WEEKLY_REPORT_HOUR")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 || v > 23 {
+			log.Printf("Invalid WEEKLY_REPORT_HOUR %q, the weekly report is not scheduled", raw)
+			return false
+		}
+		hour = v
+	}
+
+	go func() {
+		location, err := time.LoadLocation("America/Montreal")
+		if err != nil {
+			log.Printf("Failed to load Montreal timezone: %v", err)
+			return
+		}
+
+		for {
+			now := time.Now().In(location)
+
+			// Calculate the next report day at the report hour
+			nextRun := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, location)
+			nextRun = nextRun.AddDate(0, 0, (int(weekday)-int(now.Weekday())+7)%7)
+			if !nextRun.After(now) {
+				nextRun = nextRun.AddDate(0, 0, 7)
+			}
+
+			waitDuration := time.Until(nextRun)
+			log.Printf("Next weekly report scheduled for: %s (in %v)", nextRun.Format("2006-01-02 15:04:05 MST"), waitDuration)
+
+			time.Sleep(waitDuration)
+
+			s.executeReportJob()
+		}
+	}()
+
+	return true
+}
+
+// executeReportJob calls the print-report endpoint
+func (s *Scheduler) executeReportJob() {
+	log.Printf("Executing weekly report at %s", time.Now().Format("2006-01-02 15:04:05 MST"))
+
+	resp, err := s.client.Post(s.url+"/print-report", "application/json", bytes.NewBufferString("{}"))
+	if err != nil {
+		log.Printf("ERROR: Failed to call print-report endpoint: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusAccepted {
+		log.Printf("Weekly report queued successfully (status: %d)", resp.StatusCode)
+	} else {
+		log.Printf("Weekly report failed with status: %d", resp.StatusCode)
+	}
+}
+
+// parseWeekday parses an English weekday name such as Sunday or sun
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(name)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if name == full || name == full[:3] {
+			return day, true
+		}
+	}
+	return 0, false
+}
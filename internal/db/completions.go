@@ -0,0 +1,103 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrCompletionNotFound is returned when no completion matches the query
+var ErrCompletionNotFound = errors.New("completion not found")
+
+// completionColumns is the column list shared by every completion query
+const completionColumns = `id, ticket_id, print_id, source, created_at`
+
+// CreateCompletion records that a ticket was done
+func (d *Database) CreateCompletion(completion *Completion) error {
+	query := `INSERT INTO completions (ticket_id, print_id, source, created_at) VALUES (?, ?, ?, ?)`
+
+	result, err := d.db.Exec(query, completion.TicketID, nullableID(completion.PrintID), completion.Source, completion.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create completion: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %v", err)
+	}
+
+	completion.ID = int(id)
+	return nil
+}
+
+// GetCompletionByPrintID retrieves the completion of a print
+func (d *Database) GetCompletionByPrintID(printID int) (*Completion, error) {
+	query := `SELECT ` + completionColumns + ` FROM completions WHERE print_id = ? ORDER BY created_at DESC LIMIT 1`
+
+	completion, err := scanCompletion(d.db.QueryRow(query, printID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCompletionNotFound
+		}
+		return nil, fmt.Errorf("failed to get completion: %v", err)
+	}
+
+	return completion, nil
+}
+
+// GetLastCompletion retrieves the most recent completion of a ticket
+func (d *Database) GetLastCompletion(ticketID int) (*Completion, error) {
+	query := `SELECT ` + completionColumns + ` FROM completions WHERE ticket_id = ? ORDER BY created_at DESC LIMIT 1`
+
+	completion, err := scanCompletion(d.db.QueryRow(query, ticketID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCompletionNotFound
+		}
+		return nil, fmt.Errorf("failed to get completion: %v", err)
+	}
+
+	return completion, nil
+}
+
+// GetCompletionsByTicketID retrieves all completions of a ticket, most recent first
+func (d *Database) GetCompletionsByTicketID(ticketID int) ([]Completion, error) {
+	query := `SELECT ` + completionColumns + ` FROM completions WHERE ticket_id = ? ORDER BY created_at DESC`
+
+	rows, err := d.db.Query(query, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query completions: %v", err)
+	}
+	defer rows.Close()
+
+	var completions []Completion
+	for rows.Next() {
+		completion, err := scanCompletion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan completion: %v", err)
+		}
+		completions = append(completions, *completion)
+	}
+
+	return completions, nil
+}
+
+// DeleteAllCompletions deletes all completions from the database
+func (d *Database) DeleteAllCompletions() error {
+	if _, err := d.db.Exec(`DELETE FROM completions`); err != nil {
+		return fmt.Errorf("failed to delete all completions: %v", err)
+	}
+	return nil
+}
+
+// scanCompletion reads a completion from a row selected with completionColumns
+func scanCompletion(row rowScanner) (*Completion, error) {
+	completion := &Completion{}
+	var printID sql.NullInt64
+	if err := row.Scan(&completion.ID, &completion.TicketID, &printID, &completion.Source, &completion.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	completion.PrintID = int(printID.Int64)
+	return completion, nil
+}
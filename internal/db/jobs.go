@@ -0,0 +1,297 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrJobNotFound is returned when no job has the requested ID
+var ErrJobNotFound = errors.New("job not found")
+
+// jobColumns is the column list shared by every job query
+const jobColumns = `id, kind, printer, ticket_id, payload, status, attempts, max_attempts, last_error, fallback_from, next_attempt_at, created_at, updated_at`
+
+// CreateJob creates a new queued job
+func (d *Database) CreateJob(job *Job) error {
+	now := time.Now()
+	if job.Kind == "" {
+		job.Kind = JobKindTicket
+	}
+	if job.Status == "" {
+		job.Status = JobStatusQueued
+	}
+	if job.Payload == "" {
+		job.Payload = "{}"
+	}
+	if job.NextAttemptAt.IsZero() {
+		job.NextAttemptAt = now
+	}
+	job.CreatedAt = now
+	job.UpdatedAt = now
+
+	query := `
+		INSERT INTO jobs (kind, printer, ticket_id, payload, status, attempts, max_attempts, last_error, next_attempt_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := d.db.Exec(query, job.Kind, job.Printer, nullableID(job.TicketID), job.Payload, job.Status,
+		job.Attempts, job.MaxAttempts, job.LastError, job.NextAttemptAt, job.CreatedAt, job.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create job: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %v", err)
+	}
+
+	job.ID = int(id)
+	return nil
+}
+
+// GetJobByID retrieves a job by ID
+func (d *Database) GetJobByID(id int) (*Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE id = ?`
+
+	job, err := scanJob(d.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get job: %v", err)
+	}
+
+	return job, nil
+}
+
+// ClaimNextJob atomically moves the oldest due job of a printer to rendering.
+// It returns nil when no job is ready.
+func (d *Database) ClaimNextJob(printerName string) (*Job, error) {
+	now := time.Now()
+	query := `
+		UPDATE jobs
+		SET status = ?, attempts = attempts + 1, updated_at = ?
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE printer = ? AND status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at, id
+			LIMIT 1
+		)
+		RETURNING ` + jobColumns
+
+	job, err := scanJob(d.db.QueryRow(query, JobStatusRendering, now, printerName, JobStatusQueued, now))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim job: %v", err)
+	}
+
+	return job, nil
+}
+
+// UpdateJobStatus moves a job to a new status
+func (d *Database) UpdateJobStatus(id int, status string) error {
+	query := `UPDATE jobs SET status = ?, updated_at = ? WHERE id = ?`
+
+	result, err := d.db.Exec(query, status, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update job status: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// RetryJob puts a failed job back in the queue to be attempted again at nextAttempt
+func (d *Database) RetryJob(id int, lastError string, nextAttempt time.Time) error {
+	query := `UPDATE jobs SET status = ?, last_error = ?, next_attempt_at = ?, updated_at = ? WHERE id = ?`
+
+	if _, err := d.db.Exec(query, JobStatusQueued, lastError, nextAttempt, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to reschedule job: %v", err)
+	}
+
+	return nil
+}
+
+// FallbackJob moves a job that failed on its printer to the queue of the fallback printer,
+// to be attempted there right away
+func (d *Database) FallbackJob(id int, fallbackPrinter, lastError string) error {
+	now := time.Now()
+	query := `
+		UPDATE jobs
+		SET fallback_from = printer, printer = ?, status = ?, last_error = ?, next_attempt_at = ?, updated_at = ?
+		WHERE id = ?`
+
+	result, err := d.db.Exec(query, fallbackPrinter, JobStatusQueued, lastError, now, now, id)
+	if err != nil {
+		return fmt.Errorf("failed to move job to fallback printer: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// HoldJob parks a job until its printer recovers. The attempt is not counted against the job.
+func (d *Database) HoldJob(id int, reason string) error {
+	query := `UPDATE jobs SET status = ?, attempts = MAX(attempts - 1, 0), last_error = ?, updated_at = ? WHERE id = ?`
+
+	if _, err := d.db.Exec(query, JobStatusHeld, reason, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to hold job: %v", err)
+	}
+
+	return nil
+}
+
+// ReleaseHeldJobs puts the held jobs of a printer back in the queue. They are due from
+// their creation time, so they print before the jobs queued while they were held.
+func (d *Database) ReleaseHeldJobs(printerName string) (int, error) {
+	query := `UPDATE jobs SET status = ?, next_attempt_at = created_at, updated_at = ? WHERE printer = ? AND status = ?`
+
+	result, err := d.db.Exec(query, JobStatusQueued, time.Now(), printerName, JobStatusHeld)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release held jobs: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+// CountJobs returns the number of jobs of a printer with the given status
+func (d *Database) CountJobs(printerName, status string) (int, error) {
+	query := `SELECT COUNT(*) FROM jobs WHERE printer = ? AND status = ?`
+
+	var count int
+	if err := d.db.QueryRow(query, printerName, status).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count jobs: %v", err)
+	}
+
+	return count, nil
+}
+
+// FailJob marks a job as permanently failed
+func (d *Database) FailJob(id int, lastError string) error {
+	query := `UPDATE jobs SET status = ?, last_error = ?, updated_at = ? WHERE id = ?`
+
+	if _, err := d.db.Exec(query, JobStatusFailed, lastError, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to mark job as failed: %v", err)
+	}
+
+	return nil
+}
+
+// RequeueInterruptedJobs puts jobs that were in progress when the process stopped back in the queue
+func (d *Database) RequeueInterruptedJobs() (int, error) {
+	query := `UPDATE jobs SET status = ?, updated_at = ? WHERE status IN (?, ?)`
+
+	result, err := d.db.Exec(query, JobStatusQueued, time.Now(), JobStatusRendering, JobStatusPrinting)
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue interrupted jobs: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+// CreateJobEvent records a progress update of a job
+func (d *Database) CreateJobEvent(event *JobEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO job_events (job_id, stage, status, message, duration_ms, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+
+	result, err := d.db.Exec(query, event.JobID, event.Stage, event.Status, event.Message, event.DurationMs, event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create job event: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %v", err)
+	}
+
+	event.ID = int(id)
+	return nil
+}
+
+// GetJobEvents retrieves all events of a job in the order they happened
+func (d *Database) GetJobEvents(jobID int) ([]JobEvent, error) {
+	query := `SELECT id, job_id, stage, status, message, duration_ms, created_at FROM job_events WHERE job_id = ? ORDER BY id`
+
+	rows, err := d.db.Query(query, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job events: %v", err)
+	}
+	defer rows.Close()
+
+	var events []JobEvent
+	for rows.Next() {
+		var event JobEvent
+		err := rows.Scan(
+			&event.ID, &event.JobID, &event.Stage, &event.Status, &event.Message, &event.DurationMs, &event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job event: %v", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanJob reads a job from a row selected with jobColumns
+func scanJob(row rowScanner) (*Job, error) {
+	job := &Job{}
+	var ticketID sql.NullInt64
+	err := row.Scan(
+		&job.ID, &job.Kind, &job.Printer, &ticketID, &job.Payload, &job.Status,
+		&job.Attempts, &job.MaxAttempts, &job.LastError, &job.FallbackFrom, &job.NextAttemptAt, &job.CreatedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	job.TicketID = int(ticketID.Int64)
+	return job, nil
+}
+
+// nullableID stores 0 as NULL for optional foreign keys
+func nullableID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"fmt"
+	"log"
+)
+
+// legacyColumns are the columns added to the tables of databases created before migrations
+// were tracked, when their schema grew with the app
+var legacyColumns = []struct {
+	table, name, definition string
+}{
+	{"tickets", "weekdays", "TEXT NOT NULL DEFAULT ''"},
+	{"tickets", "assignee", "TEXT NOT NULL DEFAULT ''"},
+	{"tickets", "template", "TEXT NOT NULL DEFAULT ''"},
+	{"tickets", "url", "TEXT NOT NULL DEFAULT ''"},
+	{"tickets", "cooldown_from", "TEXT NOT NULL DEFAULT 'print'"},
+	{"prints", "printer", "TEXT NOT NULL DEFAULT ''"},
+	{"prints", "spool_job_id", "TEXT NOT NULL DEFAULT ''"},
+	{"prints", "spool_state", "TEXT NOT NULL DEFAULT ''"},
+	{"prints", "kind", "TEXT NOT NULL DEFAULT 'ticket'"},
+	{"prints", "source", "TEXT NOT NULL DEFAULT ''"},
+	{"jobs", "fallback_from", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// legacyPrintsSQL is the prints table of migration 1, used to rebuild older prints tables
+const legacyPrintsSQL = `
+	CREATE TABLE prints (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ticket_id INTEGER,
+		kind TEXT NOT NULL DEFAULT 'ticket',
+		source TEXT NOT NULL DEFAULT '',
+		printer TEXT NOT NULL DEFAULT '',
+		spool_job_id TEXT NOT NULL DEFAULT '',
+		spool_state TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (ticket_id) REFERENCES tickets (id) ON DELETE CASCADE
+	);`
+
+// upgradeLegacySchema brings a database created before migrations were tracked to the
+// schema of migration 1, which then creates the tables it is missing
+func (d *Database) upgradeLegacySchema() error {
+	log.Printf("♻️  Upgrading a database created before schema migrations")
+
+	for _, column := range legacyColumns {
+		if err := d.addMissingColumn(column.table, column.name, column.definition); err != nil {
+			return err
+		}
+	}
+
+	return d.allowPrintsWithoutTicket()
+}
+
+// addMissingColumn adds a column to a table that exists but does not have it yet
+func (d *Database) addMissingColumn(table, name, definition string) error {
+	exists, err := d.tableExists(table)
+	if err != nil || !exists {
+		return err
+	}
+
+	var count int
+	err = d.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, name).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s table: %v", table, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if _, err := d.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s;`, table, name, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s: %v", table, name, err)
+	}
+	return nil
+}
+
+// allowPrintsWithoutTicket drops the NOT NULL constraint of prints.ticket_id from databases
+// created before raw jobs were recorded. SQLite cannot alter a column, so the table is rebuilt.
+func (d *Database) allowPrintsWithoutTicket() error {
+	exists, err := d.tableExists("prints")
+	if err != nil || !exists {
+		return err
+	}
+
+	var notNull bool
+	err = d.db.QueryRow(`SELECT "notnull" FROM pragma_table_info('prints') WHERE name = 'ticket_id'`).Scan(&notNull)
+	if err != nil {
+		return fmt.Errorf("failed to inspect prints table: %v", err)
+	}
+	if !notNull {
+		return nil
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	columns := `id, ticket_id, kind, source, printer, spool_job_id, spool_state, created_at, updated_at`
+	for _, statement := range []string{
+		`ALTER TABLE prints RENAME TO prints_old;`,
+		legacyPrintsSQL,
+		`INSERT INTO prints (` + columns + `) SELECT ` + columns + ` FROM prints_old;`,
+		`DROP TABLE prints_old;`,
+	} {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to rebuild prints table: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to rebuild prints table: %v", err)
+	}
+
+	log.Printf("♻️  Migrated prints table to allow prints without a ticket")
+	return nil
+}
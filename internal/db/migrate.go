@@ -0,0 +1,218 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationFiles holds the schema migrations, named like 0001_initial.sql.
+// Every file is applied once, in order, inside a transaction.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.sql$`)
+
+// schemaMigrationsSQL creates the table recording the applied migrations
+const schemaMigrationsSQL = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+
+// Migration is a numbered schema change embedded in the binary
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// String returns the file name of the migration without its extension
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// MigrationStatus tells whether a migration was applied to a database
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrations returns the embedded migrations ordered by version. Versions start at 1
+// and have no gaps.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s, expected 0001_name.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+
+		content, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", entry.Name(), err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: match[2], SQL: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migration %s is out of sequence, expected version %d", migration, i+1)
+		}
+	}
+	if len(migrations) == 0 {
+		return nil, fmt.Errorf("no migrations found")
+	}
+
+	return migrations, nil
+}
+
+// SchemaVersion returns the version of the last applied migration, 0 when none was applied
+func (d *Database) SchemaVersion() (int, error) {
+	exists, err := d.tableExists("schema_migrations")
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var version int
+	if err := d.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %v", err)
+	}
+	return version, nil
+}
+
+// MigrationStatus returns every embedded migration and whether it was applied
+func (d *Database) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[int]time.Time)
+	exists, err := d.tableExists("schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		rows, err := d.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query schema migrations: %v", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var version int
+			var at time.Time
+			if err := rows.Scan(&version, &at); err != nil {
+				return nil, fmt.Errorf("failed to scan schema migration: %v", err)
+			}
+			appliedAt[version] = at
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to query schema migrations: %v", err)
+		}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		at, applied := appliedAt[migration.Version]
+		statuses = append(statuses, MigrationStatus{Migration: migration, Applied: applied, AppliedAt: at})
+	}
+	return statuses, nil
+}
+
+// Migrate applies the pending migrations in order and returns them. It refuses to touch
+// a database whose schema is newer than the migrations of this build.
+func (d *Database) Migrate() ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	latest := migrations[len(migrations)-1].Version
+
+	version, err := d.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version > latest {
+		return nil, fmt.Errorf("database schema version %d is newer than the latest migration %d of this build, upgrade printy", version, latest)
+	}
+
+	// Databases created before migrations were tracked get the columns migration 1 expects
+	if version == 0 {
+		legacy, err := d.tableExists("tickets")
+		if err != nil {
+			return nil, err
+		}
+		if legacy {
+			if err := d.upgradeLegacySchema(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, err := d.db.Exec(schemaMigrationsSQL); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+
+	var applied []Migration
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			continue
+		}
+		if err := d.applyMigration(migration); err != nil {
+			return applied, err
+		}
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// applyMigration runs a migration and records it in one transaction
+func (d *Database) applyMigration(migration Migration) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(migration.SQL); err != nil {
+		return fmt.Errorf("migration %s failed: %v", migration, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`, migration.Version, migration.Name, time.Now()); err != nil {
+		return fmt.Errorf("failed to record migration %s: %v", migration, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %v", migration, err)
+	}
+	return nil
+}
+
+// tableExists reports whether the database has a table
+func (d *Database) tableExists(name string) (bool, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count)
+	if err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("failed to inspect table %s: %v", name, err)
+	}
+	return count > 0, nil
+}
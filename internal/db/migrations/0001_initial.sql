@@ -0,0 +1,78 @@
+-- Schema of the tickets synced from Notion, their prints and completions, and the print queue.
+-- Tables are created only when missing, so databases created before migrations were
+-- tracked can be recorded at version 1 once their missing columns are added.
+
+CREATE TABLE IF NOT EXISTS tickets (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	ref_id TEXT NOT NULL UNIQUE,
+	title TEXT NOT NULL,
+	priority INTEGER NOT NULL DEFAULT 0,
+	cooldown INTEGER NOT NULL DEFAULT 0,
+	weekdays TEXT NOT NULL DEFAULT '',
+	assignee TEXT NOT NULL DEFAULT '',
+	template TEXT NOT NULL DEFAULT '',
+	url TEXT NOT NULL DEFAULT '',
+	cooldown_from TEXT NOT NULL DEFAULT 'print',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS prints (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	ticket_id INTEGER,
+	kind TEXT NOT NULL DEFAULT 'ticket',
+	source TEXT NOT NULL DEFAULT '',
+	printer TEXT NOT NULL DEFAULT '',
+	spool_job_id TEXT NOT NULL DEFAULT '',
+	spool_state TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (ticket_id) REFERENCES tickets (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS completions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	ticket_id INTEGER NOT NULL,
+	print_id INTEGER,
+	source TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (ticket_id) REFERENCES tickets (id) ON DELETE CASCADE,
+	FOREIGN KEY (print_id) REFERENCES prints (id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS jobs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL DEFAULT 'ticket',
+	printer TEXT NOT NULL DEFAULT '',
+	ticket_id INTEGER,
+	payload TEXT NOT NULL DEFAULT '{}',
+	status TEXT NOT NULL DEFAULT 'queued',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	max_attempts INTEGER NOT NULL DEFAULT 5,
+	last_error TEXT NOT NULL DEFAULT '',
+	fallback_from TEXT NOT NULL DEFAULT '',
+	next_attempt_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (ticket_id) REFERENCES tickets (id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS job_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	job_id INTEGER NOT NULL,
+	stage TEXT NOT NULL,
+	status TEXT NOT NULL,
+	message TEXT NOT NULL DEFAULT '',
+	duration_ms INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (job_id) REFERENCES jobs (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_tickets_ref_id ON tickets(ref_id);
+CREATE INDEX IF NOT EXISTS idx_tickets_priority ON tickets(priority);
+CREATE INDEX IF NOT EXISTS idx_prints_ticket_id ON prints(ticket_id);
+CREATE INDEX IF NOT EXISTS idx_prints_created_at ON prints(created_at);
+CREATE INDEX IF NOT EXISTS idx_completions_ticket_id ON completions(ticket_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_completions_print_id ON completions(print_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(printer, status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_job_events_job_id ON job_events(job_id);
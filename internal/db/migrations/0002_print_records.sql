@@ -0,0 +1,13 @@
+-- Print records keep the outcome of every attempt and what was printed, so failed prints
+-- leave a trace and a print can be reproduced. Prints recorded before are successes.
+
+ALTER TABLE prints ADD COLUMN status TEXT NOT NULL DEFAULT 'succeeded';
+ALTER TABLE prints ADD COLUMN error TEXT NOT NULL DEFAULT '';
+ALTER TABLE prints ADD COLUMN job_id INTEGER REFERENCES jobs (id) ON DELETE SET NULL;
+ALTER TABLE prints ADD COLUMN template TEXT NOT NULL DEFAULT '';
+ALTER TABLE prints ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE prints ADD COLUMN assignee TEXT NOT NULL DEFAULT '';
+ALTER TABLE prints ADD COLUMN image_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE prints ADD COLUMN render_ms INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE prints ADD COLUMN transmit_ms INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_prints_status ON prints(status);
@@ -0,0 +1,5 @@
+-- Reprints are linked to the print they replay. They do not restart the cooldown of their
+-- ticket unless it was asked for, so the cooldown queries skip them.
+
+ALTER TABLE prints ADD COLUMN reprint_of INTEGER REFERENCES prints (id) ON DELETE SET NULL;
+ALTER TABLE prints ADD COLUMN no_cooldown BOOLEAN NOT NULL DEFAULT 0;
@@ -7,23 +7,169 @@ import (
 
 // Ticket represents a ticket in the database
 type Ticket struct {
-	ID        int       `json:"id" db:"id"`
-	RefID     string    `json:"ref_id" db:"ref_id"`
-	Title     string    `json:"title" db:"title"`
-	Priority  int       `json:"priority" db:"priority"`
-	Cooldown  int       `json:"cooldown" db:"cooldown"` // Cooldown in seconds
-	Weekdays  string    `json:"weekdays" db:"weekdays"` // Weekdays as JSON array string (e.g., ["WeekEnd", "WeekDay"])
-	Assignee  string    `json:"assignee" db:"assignee"` // Assignee name from Notion user
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID           int       `json:"id" db:"id"`
+	RefID        string    `json:"ref_id" db:"ref_id"`
+	Title        string    `json:"title" db:"title"`
+	Priority     int       `json:"priority" db:"priority"`
+	Cooldown     int       `json:"cooldown" db:"cooldown"`           // Cooldown in seconds
+	Weekdays     string    `json:"weekdays" db:"weekdays"`           // Weekdays as JSON array string (e.g., ["WeekEnd", "WeekDay"])
+	Assignee     string    `json:"assignee" db:"assignee"`           // Assignee name from Notion user
+	Template     string    `json:"template" db:"template"`           // Template chosen in Notion, empty to use the selection rules
+	URL          string    `json:"url" db:"url"`                     // Notion page URL, empty for tickets synced before it was stored
+	CooldownFrom string    `json:"cooldown_from" db:"cooldown_from"` // CooldownFromPrint or CooldownFromCompletion
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// What the cooldown of a ticket is measured from
+const (
+	CooldownFromPrint      = "print"      // The last print, whether the ticket was done or not
+	CooldownFromCompletion = "completion" // The last completion, prints that are not done are offered again
+)
+
 // Print represents a print job in the database
 type Print struct {
+	ID         int       `json:"id" db:"id"`
+	TicketID   int       `json:"ticket_id,omitempty" db:"ticket_id"` // 0 for raw jobs, reports and prints without a ticket
+	Kind       string    `json:"kind" db:"kind"`                     // Kind of the job that printed it
+	Source     string    `json:"source,omitempty" db:"source"`       // Address of the client that sent a raw job
+	Printer    string    `json:"printer,omitempty" db:"printer"`
+	SpoolJobID string    `json:"spool_job_id,omitempty" db:"spool_job_id"` // Job ID given by the spooler, such as CUPS
+	SpoolState string    `json:"spool_state,omitempty" db:"spool_state"`   // Last state reported by the spooler
+	Status     string    `json:"status" db:"status"`                       // PrintStatusPrinting, PrintStatusSucceeded or PrintStatusFailed
+	Error      string    `json:"error,omitempty" db:"error"`               // Why a failed print failed
+	JobID      int       `json:"job_id,omitempty" db:"job_id"`             // Job that printed it, 0 when unknown
+	Template   string    `json:"template,omitempty" db:"template"`         // Template actually used, empty for raw jobs
+	Title      string    `json:"title,omitempty" db:"title"`               // Ticket title as it was printed
+	Assignee   string    `json:"assignee,omitempty" db:"assignee"`         // Assignee as it was printed
+	ImageHash  string    `json:"image_hash,omitempty" db:"image_hash"`     // SHA-256 of the printed dots, empty for raw jobs
+	RenderMs   int64     `json:"render_ms" db:"render_ms"`                 // Time spent rendering the template
+	TransmitMs int64     `json:"transmit_ms" db:"transmit_ms"`             // Time spent encoding and sending the job
+	ReprintOf  int       `json:"reprint_of,omitempty" db:"reprint_of"`     // Print this one replays, 0 for first prints
+	NoCooldown bool      `json:"no_cooldown,omitempty" db:"no_cooldown"`   // Reprint that leaves the cooldown of its ticket as it was
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Print statuses. Only succeeded prints count for cooldowns, completions and reports.
+const (
+	PrintStatusPrinting  = "printing" // Created before rendering so its ID can be printed
+	PrintStatusSucceeded = "succeeded"
+	PrintStatusFailed    = "failed"
+)
+
+// Completion records that a ticket was done, usually after one of its prints
+type Completion struct {
 	ID        int       `json:"id" db:"id"`
 	TicketID  int       `json:"ticket_id" db:"ticket_id"`
+	PrintID   int       `json:"print_id,omitempty" db:"print_id"` // 0 when the ticket was done without a print
+	Source    string    `json:"source" db:"source"`               // How it was reported, see CompletionSource*
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Completion sources
+const (
+	CompletionSourceAPI  = "api"  // POST /complete
+	CompletionSourceLink = "link" // Completion URL opened from the QR code of a ticket
+	CompletionSourceScan = "scan" // Code read by the barcode scanner
+)
+
+// Spool states of prints the spooler has not finished yet. Finished prints are
+// completed, aborted, cancelled or unknown once the spooler forgets them.
+const (
+	SpoolStatePending    = "pending"
+	SpoolStateHeld       = "held"
+	SpoolStateProcessing = "processing"
+)
+
+// Job statuses, in the order a job normally goes through them
+const (
+	JobStatusQueued    = "queued"
+	JobStatusHeld      = "held" // Waiting for the printer to recover from an error such as paper out
+	JobStatusRendering = "rendering"
+	JobStatusPrinting  = "printing"
+	JobStatusDone      = "done"
+	JobStatusFailed    = "failed"
+)
+
+// Job kinds
+const (
+	JobKindTicket = "ticket"
+	JobKindRaw    = "raw"    // ESC/POS data received from another application, printed as is
+	JobKindAgenda = "agenda" // Several tickets printed on one receipt with a list template
+	JobKindReport = "report" // Print statistics, computed when the job is printed
+)
+
+// Job represents a queued print job in the database
+type Job struct {
+	ID            int       `json:"id" db:"id"`
+	Kind          string    `json:"kind" db:"kind"`
+	Printer       string    `json:"printer" db:"printer"`
+	TicketID      int       `json:"ticket_id,omitempty" db:"ticket_id"` // 0 when the job is not linked to a ticket
+	Payload       string    `json:"payload" db:"payload"`               // Job fields as a JSON object (see JobPayload)
+	Status        string    `json:"status" db:"status"`
+	Attempts      int       `json:"attempts" db:"attempts"`
+	MaxAttempts   int       `json:"max_attempts" db:"max_attempts"`
+	LastError     string    `json:"last_error,omitempty" db:"last_error"`
+	FallbackFrom  string    `json:"fallback_from,omitempty" db:"fallback_from"` // Printer the job was moved away from after it failed there
+	NextAttemptAt time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// JobPayload holds the fields needed to render a job
+type JobPayload struct {
+	RefID    string `json:"ref_id,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Assignee string `json:"assignee,omitempty"`
+	Template string `json:"template,omitempty"` // Template name, empty for the default template
+	URL      string `json:"url,omitempty"`      // Notion page URL of the ticket
+
+	// Raster options that override the template, zero values are unset
+	Dither    string  `json:"dither,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+	Gamma     float64 `json:"gamma,omitempty"`
+	Contrast  float64 `json:"contrast,omitempty"`
+
+	// Raw jobs, and reprints that send again the data of an earlier job
+	Data   []byte `json:"data,omitempty"`   // ESC/POS data, base64 encoded in JSON
+	Source string `json:"source,omitempty"` // Address of the client that sent it
+
+	// Agenda jobs, the tickets as they were when the agenda was requested
+	Tickets []JobTicket `json:"tickets,omitempty"`
+
+	// Reprints, the print they replay and whether they restart the cooldown of its tickets
+	ReprintOf     int  `json:"reprint_of,omitempty"`
+	ResetCooldown bool `json:"reset_cooldown,omitempty"`
+}
+
+// JobTicket is a ticket printed as a line of an agenda job
+type JobTicket struct {
+	TicketID int    `json:"ticket_id"`
+	RefID    string `json:"ref_id,omitempty"`
+	Title    string `json:"title"`
+	Assignee string `json:"assignee,omitempty"`
+	Priority int    `json:"priority"`
+}
+
+// JobEventStageJob is the stage of events that report a job status change
+const JobEventStageJob = "job"
+
+// Job event statuses for pipeline stages
+const (
+	JobEventCompleted = "completed"
+	JobEventFailed    = "failed"
+)
+
+// JobEvent represents a progress update of a job
+type JobEvent struct {
+	ID         int       `json:"id" db:"id"`
+	JobID      int       `json:"job_id" db:"job_id"`
+	Stage      string    `json:"stage" db:"stage"`   // Pipeline stage, or "job" for status changes
+	Status     string    `json:"status" db:"status"` // Stage outcome, or the new job status
+	Message    string    `json:"message,omitempty" db:"message"`
+	DurationMs int64     `json:"duration_ms" db:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 }
 
 // TicketWithPrints represents a ticket with its associated prints
@@ -79,3 +225,37 @@ func (t *Ticket) HasWeekday(weekday string) (bool, error) {
 	}
 	return false, nil
 }
+
+// GetPayload decodes the job payload
+func (j *Job) GetPayload() (JobPayload, error) {
+	var payload JobPayload
+	if j.Payload == "" {
+		return payload, nil
+	}
+
+	if err := json.Unmarshal([]byte(j.Payload), &payload); err != nil {
+		return payload, err
+	}
+	return payload, nil
+}
+
+// SetPayload encodes the job payload
+func (j *Job) SetPayload(payload JobPayload) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	j.Payload = string(jsonData)
+	return nil
+}
+
+// IsFinished reports whether the job reached a terminal status
+func (j *Job) IsFinished() bool {
+	return j.Status == JobStatusDone || j.Status == JobStatusFailed
+}
+
+// IsFinal reports whether the event marks the end of the job
+func (e *JobEvent) IsFinal() bool {
+	return e.Stage == JobEventStageJob && (e.Status == JobStatusDone || e.Status == JobStatusFailed)
+}
@@ -2,17 +2,37 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 )
 
-// CreatePrint creates a new print record
+// ErrPrintNotFound is returned when no print has the requested ID
+var ErrPrintNotFound = errors.New("print not found")
+
+// printColumns is the column list shared by every print query
+const printColumns = `id, ticket_id, kind, source, printer, spool_job_id, spool_state, status, error, job_id,
+	template, title, assignee, image_hash, render_ms, transmit_ms, reprint_of, no_cooldown, created_at, updated_at`
+
+// CreatePrint creates a new print record, a succeeded print unless its status is set
 func (d *Database) CreatePrint(print *Print) error {
 	query := `
-		INSERT INTO prints (ticket_id, created_at, updated_at)
-		VALUES (?, ?, ?)`
+		INSERT INTO prints (ticket_id, kind, source, printer, spool_job_id, spool_state, status, error, job_id,
+			template, title, assignee, image_hash, render_ms, transmit_ms, reprint_of, no_cooldown, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if print.Kind == "" {
+		print.Kind = JobKindTicket
+	}
+	if print.Status == "" {
+		print.Status = PrintStatusSucceeded
+	}
 
-	result, err := d.db.Exec(query, print.TicketID, print.CreatedAt, print.UpdatedAt)
+	result, err := d.db.Exec(query,
+		nullableID(print.TicketID), print.Kind, print.Source, print.Printer, print.SpoolJobID, print.SpoolState, print.Status, print.Error, nullableID(print.JobID),
+		print.Template, print.Title, print.Assignee, print.ImageHash, print.RenderMs, print.TransmitMs, nullableID(print.ReprintOf), print.NoCooldown,
+		print.CreatedAt, print.UpdatedAt,
+	)
 	if err != nil {
 		return fmt.Errorf("failed to create print: %v", err)
 	}
@@ -28,16 +48,12 @@ func (d *Database) CreatePrint(print *Print) error {
 
 // GetPrintByID retrieves a print by ID
 func (d *Database) GetPrintByID(id int) (*Print, error) {
-	query := `SELECT id, ticket_id, created_at, updated_at FROM prints WHERE id = ?`
-
-	print := &Print{}
-	err := d.db.QueryRow(query, id).Scan(
-		&print.ID, &print.TicketID, &print.CreatedAt, &print.UpdatedAt,
-	)
+	query := `SELECT ` + printColumns + ` FROM prints WHERE id = ?`
 
+	print, err := scanPrint(d.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("print not found")
+			return nil, ErrPrintNotFound
 		}
 		return nil, fmt.Errorf("failed to get print: %v", err)
 	}
@@ -47,7 +63,7 @@ func (d *Database) GetPrintByID(id int) (*Print, error) {
 
 // GetPrintsByTicketID retrieves all prints for a specific ticket
 func (d *Database) GetPrintsByTicketID(ticketID int) ([]Print, error) {
-	query := `SELECT id, ticket_id, created_at, updated_at FROM prints WHERE ticket_id = ? ORDER BY created_at DESC`
+	query := `SELECT ` + printColumns + ` FROM prints WHERE ticket_id = ? ORDER BY created_at DESC`
 
 	rows, err := d.db.Query(query, ticketID)
 	if err != nil {
@@ -57,14 +73,11 @@ func (d *Database) GetPrintsByTicketID(ticketID int) ([]Print, error) {
 
 	var prints []Print
 	for rows.Next() {
-		var print Print
-		err := rows.Scan(
-			&print.ID, &print.TicketID, &print.CreatedAt, &print.UpdatedAt,
-		)
+		print, err := scanPrint(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan print: %v", err)
 		}
-		prints = append(prints, print)
+		prints = append(prints, *print)
 	}
 
 	return prints, nil
@@ -72,7 +85,7 @@ func (d *Database) GetPrintsByTicketID(ticketID int) ([]Print, error) {
 
 // GetAllPrints retrieves all prints
 func (d *Database) GetAllPrints() ([]Print, error) {
-	query := `SELECT id, ticket_id, created_at, updated_at FROM prints ORDER BY created_at DESC`
+	query := `SELECT ` + printColumns + ` FROM prints ORDER BY created_at DESC`
 
 	rows, err := d.db.Query(query)
 	if err != nil {
@@ -82,14 +95,11 @@ func (d *Database) GetAllPrints() ([]Print, error) {
 
 	var prints []Print
 	for rows.Next() {
-		var print Print
-		err := rows.Scan(
-			&print.ID, &print.TicketID, &print.CreatedAt, &print.UpdatedAt,
-		)
+		print, err := scanPrint(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan print: %v", err)
 		}
-		prints = append(prints, print)
+		prints = append(prints, *print)
 	}
 
 	return prints, nil
@@ -117,9 +127,10 @@ func (d *Database) GetTicketWithPrints(ticketID int) (*TicketWithPrints, error)
 
 // GetPrintsByDateRange retrieves prints within a date range
 func (d *Database) GetPrintsByDateRange(startDate, endDate time.Time) ([]Print, error) {
-	query := `SELECT id, ticket_id, created_at, updated_at FROM prints WHERE created_at BETWEEN ? AND ? ORDER BY created_at DESC`
+	query := `SELECT ` + printColumns + ` FROM prints WHERE created_at BETWEEN ? AND ? ORDER BY created_at DESC`
 
-	rows, err := d.db.Query(query, startDate, endDate)
+	// Timestamps are stored as text in the local time zone, bounds must use it to compare
+	rows, err := d.db.Query(query, startDate.In(time.Local), endDate.In(time.Local))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query prints by date range: %v", err)
 	}
@@ -127,69 +138,131 @@ func (d *Database) GetPrintsByDateRange(startDate, endDate time.Time) ([]Print,
 
 	var prints []Print
 	for rows.Next() {
-		var print Print
-		err := rows.Scan(
-			&print.ID, &print.TicketID, &print.CreatedAt, &print.UpdatedAt,
-		)
+		print, err := scanPrint(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan print: %v", err)
 		}
-		prints = append(prints, print)
+		prints = append(prints, *print)
 	}
 
 	return prints, nil
 }
 
-// GetPrintStats returns statistics about prints
-func (d *Database) GetPrintStats() (map[string]interface{}, error) {
-	stats := make(map[string]interface{})
+// GetSpooledPrints retrieves the prints of a printer that its spooler has not finished yet
+func (d *Database) GetSpooledPrints(printerName string) ([]Print, error) {
+	query := `SELECT ` + printColumns + ` FROM prints
+		WHERE printer = ? AND spool_job_id != '' AND spool_state IN (?, ?, ?)
+		ORDER BY id`
+
+	rows, err := d.db.Query(query, printerName, SpoolStatePending, SpoolStateHeld, SpoolStateProcessing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query spooled prints: %v", err)
+	}
+	defer rows.Close()
+
+	var prints []Print
+	for rows.Next() {
+		print, err := scanPrint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan print: %v", err)
+		}
+		prints = append(prints, *print)
+	}
+
+	return prints, nil
+}
+
+// FinishPrint records the outcome of a print created before it was printed
+func (d *Database) FinishPrint(print *Print) error {
+	query := `
+		UPDATE prints SET status = ?, error = ?, spool_job_id = ?, spool_state = ?, template = ?, image_hash = ?,
+			render_ms = ?, transmit_ms = ?, updated_at = ?
+		WHERE id = ?`
+
+	print.UpdatedAt = time.Now()
+	result, err := d.db.Exec(query,
+		print.Status, print.Error, print.SpoolJobID, print.SpoolState, print.Template, print.ImageHash,
+		print.RenderMs, print.TransmitMs, print.UpdatedAt, print.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to finish print: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrPrintNotFound
+	}
+
+	return nil
+}
+
+// FailInterruptedPrints marks the prints that were being printed when the process stopped as failed
+func (d *Database) FailInterruptedPrints() (int, error) {
+	query := `UPDATE prints SET status = ?, error = ?, updated_at = ? WHERE status = ?`
 
-	// Total prints count
-	var totalPrints int
-	err := d.db.QueryRow("SELECT COUNT(*) FROM prints").Scan(&totalPrints)
+	result, err := d.db.Exec(query, PrintStatusFailed, "interrupted by a restart", time.Now(), PrintStatusPrinting)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get total prints count: %v", err)
+		return 0, fmt.Errorf("failed to fail interrupted prints: %v", err)
 	}
-	stats["total_prints"] = totalPrints
 
-	// Total tickets count
-	var totalTickets int
-	err = d.db.QueryRow("SELECT COUNT(*) FROM tickets").Scan(&totalTickets)
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get total tickets count: %v", err)
+		return 0, fmt.Errorf("failed to get rows affected: %v", err)
 	}
-	stats["total_tickets"] = totalTickets
 
-	// Prints today
-	today := time.Now().Truncate(24 * time.Hour)
-	tomorrow := today.Add(24 * time.Hour)
-	var printsToday int
-	err = d.db.QueryRow("SELECT COUNT(*) FROM prints WHERE created_at BETWEEN ? AND ?", today, tomorrow).Scan(&printsToday)
+	return int(rowsAffected), nil
+}
+
+// UpdatePrintSpoolState records the state the spooler reports for a print
+func (d *Database) UpdatePrintSpoolState(id int, state string) error {
+	query := `UPDATE prints SET spool_state = ?, updated_at = ? WHERE id = ?`
+
+	result, err := d.db.Exec(query, state, time.Now(), id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get prints today count: %v", err)
+		return fmt.Errorf("failed to update print spool state: %v", err)
 	}
-	stats["prints_today"] = printsToday
 
-	// Most printed ticket
-	var mostPrintedTicketID int
-	var mostPrintedCount int
-	err = d.db.QueryRow(`
-		SELECT ticket_id, COUNT(*) as print_count 
-		FROM prints 
-		GROUP BY ticket_id 
-		ORDER BY print_count DESC 
-		LIMIT 1
-	`).Scan(&mostPrintedTicketID, &mostPrintedCount)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to get most printed ticket: %v", err)
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
 	}
 
-	if err == nil {
-		stats["most_printed_ticket_id"] = mostPrintedTicketID
-		stats["most_printed_count"] = mostPrintedCount
+	if rowsAffected == 0 {
+		return ErrPrintNotFound
 	}
 
-	return stats, nil
+	return nil
+}
+
+// GetLastPrintTimes returns the time of the last succeeded print of every printed ticket
+func (d *Database) GetLastPrintTimes() (map[int]time.Time, error) {
+	query := `
+		SELECT ticket_id, created_at FROM prints AS p
+		WHERE ticket_id IS NOT NULL AND status = ? AND created_at = (
+			SELECT MAX(created_at) FROM prints WHERE ticket_id = p.ticket_id AND status = ?
+		)`
+
+	rows, err := d.db.Query(query, PrintStatusSucceeded, PrintStatusSucceeded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query last print times: %v", err)
+	}
+	defer rows.Close()
+
+	lastPrinted := make(map[int]time.Time)
+	for rows.Next() {
+		var ticketID int
+		var createdAt time.Time
+		if err := rows.Scan(&ticketID, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan last print time: %v", err)
+		}
+		lastPrinted[ticketID] = createdAt
+	}
+
+	return lastPrinted, rows.Err()
 }
 
 // DeletePrint deletes a print record
@@ -207,7 +280,7 @@ func (d *Database) DeletePrint(id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("print not found")
+		return ErrPrintNotFound
 	}
 
 	return nil
@@ -236,3 +309,22 @@ func (d *Database) DeleteAllTickets() error {
 
 	return nil
 }
+
+// scanPrint reads a print from a row selected with printColumns
+func scanPrint(row rowScanner) (*Print, error) {
+	print := &Print{}
+	var ticketID, jobID, reprintOf sql.NullInt64
+	err := row.Scan(
+		&print.ID, &ticketID, &print.Kind, &print.Source, &print.Printer, &print.SpoolJobID, &print.SpoolState, &print.Status, &print.Error, &jobID,
+		&print.Template, &print.Title, &print.Assignee, &print.ImageHash, &print.RenderMs, &print.TransmitMs, &reprintOf, &print.NoCooldown,
+		&print.CreatedAt, &print.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	print.TicketID = int(ticketID.Int64)
+	print.JobID = int(jobID.Int64)
+	print.ReprintOf = int(reprintOf.Int64)
+	return print, nil
+}
@@ -2,17 +2,25 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 )
 
+// ErrTicketNotFound is returned when no ticket has the requested ID or reference ID
+var ErrTicketNotFound = errors.New("ticket not found")
+
 // CreateTicket creates a new ticket
 func (d *Database) CreateTicket(ticket *Ticket) error {
+	if ticket.CooldownFrom == "" {
+		ticket.CooldownFrom = CooldownFromPrint
+	}
+
 	query := `
-		INSERT INTO tickets (ref_id, title, priority, cooldown, weekdays, assignee, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+		INSERT INTO tickets (ref_id, title, priority, cooldown, weekdays, assignee, template, url, cooldown_from, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	result, err := d.db.Exec(query, ticket.RefID, ticket.Title, ticket.Priority, ticket.Cooldown, ticket.Weekdays, ticket.Assignee, ticket.CreatedAt, ticket.UpdatedAt)
+	result, err := d.db.Exec(query, ticket.RefID, ticket.Title, ticket.Priority, ticket.Cooldown, ticket.Weekdays, ticket.Assignee, ticket.Template, ticket.URL, ticket.CooldownFrom, ticket.CreatedAt, ticket.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to create ticket: %v", err)
 	}
@@ -28,17 +36,17 @@ func (d *Database) CreateTicket(ticket *Ticket) error {
 
 // GetTicketByID retrieves a ticket by ID
 func (d *Database) GetTicketByID(id int) (*Ticket, error) {
-	query := `SELECT id, ref_id, title, priority, cooldown, weekdays, assignee, created_at, updated_at FROM tickets WHERE id = ?`
+	query := `SELECT id, ref_id, title, priority, cooldown, weekdays, assignee, template, url, cooldown_from, created_at, updated_at FROM tickets WHERE id = ?`
 
 	ticket := &Ticket{}
 	err := d.db.QueryRow(query, id).Scan(
 		&ticket.ID, &ticket.RefID, &ticket.Title, &ticket.Priority,
-		&ticket.Cooldown, &ticket.Weekdays, &ticket.Assignee, &ticket.CreatedAt, &ticket.UpdatedAt,
+		&ticket.Cooldown, &ticket.Weekdays, &ticket.Assignee, &ticket.Template, &ticket.URL, &ticket.CooldownFrom, &ticket.CreatedAt, &ticket.UpdatedAt,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("ticket not found")
+			return nil, ErrTicketNotFound
 		}
 		return nil, fmt.Errorf("failed to get ticket: %v", err)
 	}
@@ -48,17 +56,17 @@ func (d *Database) GetTicketByID(id int) (*Ticket, error) {
 
 // GetTicketByRefID retrieves a ticket by reference ID
 func (d *Database) GetTicketByRefID(refID string) (*Ticket, error) {
-	query := `SELECT id, ref_id, title, priority, cooldown, weekdays, assignee, created_at, updated_at FROM tickets WHERE ref_id = ?`
+	query := `SELECT id, ref_id, title, priority, cooldown, weekdays, assignee, template, url, cooldown_from, created_at, updated_at FROM tickets WHERE ref_id = ?`
 
 	ticket := &Ticket{}
 	err := d.db.QueryRow(query, refID).Scan(
 		&ticket.ID, &ticket.RefID, &ticket.Title, &ticket.Priority,
-		&ticket.Cooldown, &ticket.Weekdays, &ticket.Assignee, &ticket.CreatedAt, &ticket.UpdatedAt,
+		&ticket.Cooldown, &ticket.Weekdays, &ticket.Assignee, &ticket.Template, &ticket.URL, &ticket.CooldownFrom, &ticket.CreatedAt, &ticket.UpdatedAt,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("ticket not found")
+			return nil, ErrTicketNotFound
 		}
 		return nil, fmt.Errorf("failed to get ticket: %v", err)
 	}
@@ -68,7 +76,7 @@ func (d *Database) GetTicketByRefID(refID string) (*Ticket, error) {
 
 // GetAllTickets retrieves all tickets
 func (d *Database) GetAllTickets() ([]Ticket, error) {
-	query := `SELECT id, ref_id, title, priority, cooldown, weekdays, assignee, created_at, updated_at FROM tickets ORDER BY created_at DESC`
+	query := `SELECT id, ref_id, title, priority, cooldown, weekdays, assignee, template, url, cooldown_from, created_at, updated_at FROM tickets ORDER BY created_at DESC`
 
 	rows, err := d.db.Query(query)
 	if err != nil {
@@ -81,7 +89,7 @@ func (d *Database) GetAllTickets() ([]Ticket, error) {
 		var ticket Ticket
 		err := rows.Scan(
 			&ticket.ID, &ticket.RefID, &ticket.Title, &ticket.Priority,
-			&ticket.Cooldown, &ticket.Weekdays, &ticket.Assignee, &ticket.CreatedAt, &ticket.UpdatedAt,
+			&ticket.Cooldown, &ticket.Weekdays, &ticket.Assignee, &ticket.Template, &ticket.URL, &ticket.CooldownFrom, &ticket.CreatedAt, &ticket.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan ticket: %v", err)
@@ -96,10 +104,10 @@ func (d *Database) GetAllTickets() ([]Ticket, error) {
 func (d *Database) UpdateTicket(ticket *Ticket) error {
 	query := `
 		UPDATE tickets 
-		SET ref_id = ?, title = ?, priority = ?, cooldown = ?, weekdays = ?, assignee = ?, updated_at = ?
+		SET ref_id = ?, title = ?, priority = ?, cooldown = ?, weekdays = ?, assignee = ?, template = ?, url = ?, cooldown_from = ?, updated_at = ?
 		WHERE id = ?`
 
-	result, err := d.db.Exec(query, ticket.RefID, ticket.Title, ticket.Priority, ticket.Cooldown, ticket.Weekdays, ticket.Assignee, ticket.UpdatedAt, ticket.ID)
+	result, err := d.db.Exec(query, ticket.RefID, ticket.Title, ticket.Priority, ticket.Cooldown, ticket.Weekdays, ticket.Assignee, ticket.Template, ticket.URL, ticket.CooldownFrom, ticket.UpdatedAt, ticket.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update ticket: %v", err)
 	}
@@ -110,7 +118,7 @@ func (d *Database) UpdateTicket(ticket *Ticket) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("ticket not found")
+		return ErrTicketNotFound
 	}
 
 	return nil
@@ -131,7 +139,7 @@ func (d *Database) DeleteTicket(id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("ticket not found")
+		return ErrTicketNotFound
 	}
 
 	return nil
@@ -139,7 +147,7 @@ func (d *Database) DeleteTicket(id int) error {
 
 // GetTicketsByPriority retrieves tickets by priority level
 func (d *Database) GetTicketsByPriority(priority int) ([]Ticket, error) {
-	query := `SELECT id, ref_id, title, priority, cooldown, weekdays, assignee, created_at, updated_at FROM tickets WHERE priority = ? ORDER BY created_at DESC`
+	query := `SELECT id, ref_id, title, priority, cooldown, weekdays, assignee, template, url, cooldown_from, created_at, updated_at FROM tickets WHERE priority = ? ORDER BY created_at DESC`
 
 	rows, err := d.db.Query(query, priority)
 	if err != nil {
@@ -152,7 +160,7 @@ func (d *Database) GetTicketsByPriority(priority int) ([]Ticket, error) {
 		var ticket Ticket
 		err := rows.Scan(
 			&ticket.ID, &ticket.RefID, &ticket.Title, &ticket.Priority,
-			&ticket.Cooldown, &ticket.Weekdays, &ticket.Assignee, &ticket.CreatedAt, &ticket.UpdatedAt,
+			&ticket.Cooldown, &ticket.Weekdays, &ticket.Assignee, &ticket.Template, &ticket.URL, &ticket.CooldownFrom, &ticket.CreatedAt, &ticket.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan ticket: %v", err)
@@ -163,8 +171,10 @@ func (d *Database) GetTicketsByPriority(priority int) ([]Ticket, error) {
 	return tickets, nil
 }
 
-// IsTicketInCooldown checks if a ticket is still in cooldown period
-func (d *Database) IsTicketInCooldown(ticketID int) (bool, error) {
+// IsTicketInCooldown checks if a ticket is still in cooldown period.
+// Tickets whose cooldown starts at completion stay out of the backlog after a print
+// until they are done, or until grace has passed so a print that was not done is offered again.
+func (d *Database) IsTicketInCooldown(ticketID int, grace time.Duration) (bool, error) {
 	ticket, err := d.GetTicketByID(ticketID)
 	if err != nil {
 		return false, err
@@ -173,21 +183,40 @@ func (d *Database) IsTicketInCooldown(ticketID int) (bool, error) {
 	if ticket.Cooldown <= 0 {
 		return false, nil
 	}
+	cooldownDuration := time.Duration(ticket.Cooldown) * time.Second
 
-	// Get the last print for this ticket
-	query := `SELECT created_at FROM prints WHERE ticket_id = ? ORDER BY created_at DESC LIMIT 1`
+	// Get the last print for this ticket, failed prints did not give it to anyone and
+	// reprints of a lost receipt leave the cooldown as it was
+	query := `SELECT created_at FROM prints WHERE ticket_id = ? AND status = ? AND NOT no_cooldown ORDER BY created_at DESC LIMIT 1`
 	var lastPrintTime time.Time
-	err = d.db.QueryRow(query, ticketID).Scan(&lastPrintTime)
+	err = d.db.QueryRow(query, ticketID, PrintStatusSucceeded).Scan(&lastPrintTime)
+	printed := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("failed to get last print time: %v", err)
+	}
 
-	if err == sql.ErrNoRows {
-		// No prints yet, not in cooldown
-		return false, nil
+	if ticket.CooldownFrom != CooldownFromCompletion {
+		if !printed {
+			// No prints yet, not in cooldown
+			return false, nil
+		}
+		// Check if cooldown period has passed
+		return time.Since(lastPrintTime) < cooldownDuration, nil
 	}
-	if err != nil {
-		return false, fmt.Errorf("failed to get last print time: %v", err)
+
+	lastCompletion, err := d.GetLastCompletion(ticketID)
+	if err != nil && !errors.Is(err, ErrCompletionNotFound) {
+		return false, err
 	}
 
-	// Check if cooldown period has passed
-	cooldownDuration := time.Duration(ticket.Cooldown) * time.Second
-	return time.Since(lastPrintTime) < cooldownDuration, nil
+	switch {
+	case lastCompletion != nil && (!printed || !lastCompletion.CreatedAt.Before(lastPrintTime)):
+		// Done since the last print, the cooldown runs from the completion
+		return time.Since(lastCompletion.CreatedAt) < cooldownDuration, nil
+	case printed:
+		// Printed but not done yet, wait for the grace period before printing it again
+		return time.Since(lastPrintTime) < grace, nil
+	default:
+		return false, nil
+	}
 }
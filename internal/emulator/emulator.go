@@ -0,0 +1,116 @@
+package emulator
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"sync"
+	"time"
+)
+
+// DefaultCapacity is the number of jobs kept when no capacity is given
+const DefaultCapacity = 20
+
+// Job is an ESC/POS job captured by the emulator
+type Job struct {
+	Data       []byte
+	ReceivedAt time.Time
+}
+
+// Emulator is a fake printer that keeps the most recent jobs it received.
+// It satisfies printer.StatusTransport so it can replace a real printer.
+type Emulator struct {
+	mu         sync.Mutex
+	capacity   int
+	paperWidth int
+	jobs       []Job
+	status     Status
+}
+
+// New creates an emulator that keeps the last capacity jobs
+func New(capacity int) *Emulator {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+
+	return &Emulator{
+		capacity:   capacity,
+		paperWidth: DefaultPaperWidth,
+	}
+}
+
+// SetPaperWidth sets the width in dots of the virtual paper receipts are rendered on
+func (e *Emulator) SetPaperWidth(dots int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if dots > 0 {
+		e.paperWidth = dots
+	}
+}
+
+// Send records a job as if it was printed
+func (e *Emulator) Send(data []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	job := Job{
+		Data:       make([]byte, len(data)),
+		ReceivedAt: time.Now(),
+	}
+	copy(job.Data, data)
+
+	e.jobs = append(e.jobs, job)
+	if len(e.jobs) > e.capacity {
+		e.jobs = e.jobs[len(e.jobs)-e.capacity:]
+	}
+
+	return nil
+}
+
+// Jobs returns up to the last n jobs, oldest first
+func (e *Emulator) Jobs(n int) []Job {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if n <= 0 || n > len(e.jobs) {
+		n = len(e.jobs)
+	}
+
+	jobs := make([]Job, n)
+	copy(jobs, e.jobs[len(e.jobs)-n:])
+	return jobs
+}
+
+// Receipt renders the last n jobs as one continuous virtual receipt
+func (e *Emulator) Receipt(n int) (image.Image, error) {
+	jobs := e.Jobs(n)
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no jobs have been printed")
+	}
+
+	e.mu.Lock()
+	paperWidth := e.paperWidth
+	e.mu.Unlock()
+
+	var pages []image.Image
+	height := 0
+	for i, job := range jobs {
+		page, err := RenderWidth(job.Data, paperWidth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render job %d: %v", i+1, err)
+		}
+		pages = append(pages, page)
+		height += page.Bounds().Dy()
+	}
+
+	receipt := newPaper(paperWidth, height)
+	y := 0
+	for _, page := range pages {
+		bounds := page.Bounds()
+		draw.Draw(receipt, image.Rect(0, y, bounds.Dx(), y+bounds.Dy()), page, bounds.Min, draw.Src)
+		y += bounds.Dy()
+	}
+
+	return receipt, nil
+}
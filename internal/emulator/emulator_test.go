@@ -0,0 +1,91 @@
+package emulator_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"printy/internal/emulator"
+	"printy/internal/printer"
+)
+
+// testProfile is a 16 dot wide printer that understands raster images and status requests
+var testProfile = printer.Profile{
+	Name:      "test",
+	WidthDots: 16,
+	DPI:       203,
+	Commands:  []string{printer.CommandRaster, printer.CommandStatus},
+}
+
+func TestEmulatorReceipt(t *testing.T) {
+	emu := emulator.New(0)
+	emu.SetPaperWidth(testProfile.WidthDots)
+	ip := printer.NewImagePrinter(emu, testProfile)
+
+	// Black on the left half and white on the right half
+	img := image.NewGray(image.Rect(0, 0, 16, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 16; x++ {
+			img.SetGray(x, y, color.Gray{Y: 0xFF})
+			if x < 8 {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			}
+		}
+	}
+
+	if _, err := ip.PrintImage(img, printer.RasterOptions{Dither: printer.DitherThreshold}, nil, nil, nil); err != nil {
+		t.Fatalf("PrintImage: %v", err)
+	}
+	if jobs := emu.Jobs(0); len(jobs) != 1 {
+		t.Fatalf("emulator received %d jobs, want 1", len(jobs))
+	}
+
+	receipt, err := emu.Receipt(1)
+	if err != nil {
+		t.Fatalf("Receipt: %v", err)
+	}
+	bounds := receipt.Bounds()
+	if bounds.Dx() != 16 || bounds.Dy() < 4 {
+		t.Fatalf("receipt is %dx%d, want 16 wide and at least 4 tall", bounds.Dx(), bounds.Dy())
+	}
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 16; x++ {
+			gray := color.GrayModel.Convert(receipt.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray)
+			if black := gray.Y < 0x80; black != (x < 8) {
+				t.Fatalf("dot (%d, %d) black = %v, want %v", x, y, black, x < 8)
+			}
+		}
+	}
+}
+
+func TestEmulatorStatus(t *testing.T) {
+	emu := emulator.New(0)
+	ip := printer.NewImagePrinter(emu, testProfile)
+
+	status, err := ip.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !status.Supported || !status.Ready() {
+		t.Errorf("status = %+v, want a supported printer that is ready", status)
+	}
+
+	emu.SetStatus(emulator.Status{PaperOut: true})
+	status, err = ip.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !status.PaperOut || status.Ready() {
+		t.Errorf("status = %+v, want a printer out of paper", status)
+	}
+
+	emu.SetStatus(emulator.Status{CoverOpen: true})
+	status, err = ip.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !status.CoverOpen || status.Ready() {
+		t.Errorf("status = %+v, want a printer with its cover open", status)
+	}
+}
@@ -0,0 +1,459 @@
+package emulator
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"printy/internal/qrcode"
+)
+
+// ESC/POS control bytes understood by the emulator
+const (
+	lf  = 0x0A
+	esc = 0x1B
+	gs  = 0x1D
+	end = 0xFA // Session terminator written by the escpos library
+)
+
+const (
+	// DefaultPaperWidth is the printable width in dots of the emulated paper
+	DefaultPaperWidth = 512
+
+	// lineHeight is the default ESC/POS line spacing in dots (1/6 inch at 203 dpi)
+	lineHeight = 34
+
+	// cutMarkHeight is the vertical space used to draw a cut mark
+	cutMarkHeight = 24
+)
+
+var (
+	paperColor   = color.Gray{Y: 0xFF}
+	dotColor     = color.Gray{Y: 0x00}
+	cutMarkColor = color.Gray{Y: 0x99}
+)
+
+// Render parses an ESC/POS byte stream and draws it onto a virtual receipt
+func Render(data []byte) (image.Image, error) {
+	return RenderWidth(data, DefaultPaperWidth)
+}
+
+// RenderWidth parses an ESC/POS byte stream onto paper of the given width in dots
+func RenderWidth(data []byte, paperWidth int) (image.Image, error) {
+	r := &receipt{
+		width:       paperWidth,
+		img:         newPaper(paperWidth, lineHeight*4),
+		lineSpacing: lineHeight,
+		qrModule:    3,
+	}
+
+	if err := r.parse(data); err != nil {
+		return nil, err
+	}
+
+	return r.image(), nil
+}
+
+// receipt holds the state of the emulated print head
+type receipt struct {
+	width int
+	img   *image.Gray
+	y     int // Current vertical position in dots
+	x     int // Current horizontal position of pending text in dots
+	align byte
+
+	lineSpacing int // Dots fed by a line feed, changed with ESC 3
+
+	// QR code symbol set up with GS ( k, printed by function 81
+	qrModule int
+	qrLevel  qrcode.Level
+	qrData   []byte
+}
+
+// parse walks the byte stream and executes every command
+func (r *receipt) parse(data []byte) error {
+	for i := 0; i < len(data); {
+		b := data[i]
+		switch b {
+		case lf:
+			r.feed(r.lineSpacing)
+			i++
+		case end:
+			i++
+		case esc:
+			n, err := r.parseEsc(data[i:])
+			if err != nil {
+				return fmt.Errorf("offset %d: %v", i, err)
+			}
+			i += n
+		case gs:
+			n, err := r.parseGs(data[i:])
+			if err != nil {
+				return fmt.Errorf("offset %d: %v", i, err)
+			}
+			i += n
+		default:
+			if b >= 0x20 && b < 0x7F {
+				r.text(b)
+			}
+			i++
+		}
+	}
+
+	return nil
+}
+
+// parseEsc executes an ESC command and returns the number of bytes consumed
+func (r *receipt) parseEsc(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, fmt.Errorf("truncated ESC command")
+	}
+
+	switch data[1] {
+	case '@': // Initialize
+		r.align = 0
+		r.x = 0
+		r.lineSpacing = lineHeight
+		return 2, nil
+	case '2': // Default line spacing
+		r.lineSpacing = lineHeight
+		return 2, nil
+	case '3': // Set line spacing in dots
+		if len(data) < 3 {
+			return 0, fmt.Errorf("truncated ESC 3 command")
+		}
+		r.lineSpacing = int(data[2])
+		return 3, nil
+	case '*': // Select bit-image mode
+		return r.bitImage(data)
+	case 'a': // Select justification
+		if len(data) < 3 {
+			return 0, fmt.Errorf("truncated ESC a command")
+		}
+		r.align = data[2] % 0x30
+		return 3, nil
+	case 'd': // Print and feed n lines
+		if len(data) < 3 {
+			return 0, fmt.Errorf("truncated ESC d command")
+		}
+		r.feed(int(data[2]) * r.lineSpacing)
+		return 3, nil
+	case 'J': // Print and feed n dots
+		if len(data) < 3 {
+			return 0, fmt.Errorf("truncated ESC J command")
+		}
+		r.feed(int(data[2]))
+		return 3, nil
+	case '!', '-', 'E', 'G', 'M', 'V', 'p', 't', 'R', '{':
+		// Text styling and hardware commands do not change the layout
+		return escArgLength(data[1]) + 2, nil
+	default:
+		return 2, nil
+	}
+}
+
+// escArgLength returns the number of argument bytes for styling ESC commands
+func escArgLength(cmd byte) int {
+	switch cmd {
+	case 'p':
+		return 3
+	default:
+		return 1
+	}
+}
+
+// parseGs executes a GS command and returns the number of bytes consumed
+func (r *receipt) parseGs(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, fmt.Errorf("truncated GS command")
+	}
+
+	switch data[1] {
+	case 'v': // Print raster bit image
+		return r.rasterImage(data)
+	case '(':
+		return r.parseGsParen(data)
+	case 'V': // Cut paper
+		if len(data) < 3 {
+			return 0, fmt.Errorf("truncated GS V command")
+		}
+		r.cut()
+		if data[2] == 'A' || data[2] == 'B' { // Function B carries a feed amount
+			return 4, nil
+		}
+		return 3, nil
+	case '!', 'B', 'b', 'h', 'w', 'H', 'f':
+		return 3, nil
+	default:
+		return 2, nil
+	}
+}
+
+// parseGsParen executes a GS ( command, which carries its parameter length, and returns
+// the number of bytes consumed. Only the QR code functions of GS ( k are printed.
+func (r *receipt) parseGsParen(data []byte) (int, error) {
+	if len(data) < 5 {
+		return 0, fmt.Errorf("truncated GS ( command")
+	}
+
+	length := int(data[3]) | int(data[4])<<8
+	if len(data) < 5+length {
+		return 0, fmt.Errorf("GS ( %c needs %d bytes but only %d remain", data[2], length, len(data)-5)
+	}
+	params := data[5 : 5+length]
+	if data[2] != 'k' || len(params) < 2 || params[0] != '1' {
+		return 5 + length, nil
+	}
+
+	switch params[1] {
+	case 'C': // Module size
+		if len(params) > 2 && params[2] >= 1 && params[2] <= 16 {
+			r.qrModule = int(params[2])
+		}
+	case 'E': // Error correction level
+		if len(params) > 2 && params[2] >= '0' && params[2] <= '3' {
+			r.qrLevel = qrcode.Level(params[2] - '0')
+		}
+	case 'P': // Store the data
+		if len(params) > 2 {
+			r.qrData = append([]byte(nil), params[3:]...)
+		}
+	case 'Q': // Print the stored data
+		if err := r.qrCode(); err != nil {
+			return 0, err
+		}
+	}
+
+	return 5 + length, nil
+}
+
+// qrCode draws the stored QR code with its quiet zone at the current justification
+func (r *receipt) qrCode() error {
+	if len(r.qrData) == 0 {
+		return nil
+	}
+
+	code, err := qrcode.Encode(r.qrData, r.qrLevel)
+	if err != nil {
+		return fmt.Errorf("failed to encode QR code: %v", err)
+	}
+
+	r.flushText()
+
+	side := (code.Size + 2*qrcode.QuietZone) * r.qrModule
+	left := r.alignedX(side) + qrcode.QuietZone*r.qrModule
+	top := r.y + qrcode.QuietZone*r.qrModule
+	r.ensureHeight(r.y + side)
+
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Black(x, y) {
+				continue
+			}
+			module := image.Rect(left+x*r.qrModule, top+y*r.qrModule, left+(x+1)*r.qrModule, top+(y+1)*r.qrModule)
+			draw.Draw(r.img, module.Intersect(r.img.Bounds()), image.NewUniform(dotColor), image.Point{}, draw.Src)
+		}
+	}
+	r.y += side
+
+	return nil
+}
+
+// rasterImage draws a GS v 0 raster image and returns the bytes consumed
+func (r *receipt) rasterImage(data []byte) (int, error) {
+	if len(data) < 8 {
+		return 0, fmt.Errorf("truncated GS v 0 header")
+	}
+
+	bytesWidth := int(data[4]) | int(data[5])<<8
+	height := int(data[6]) | int(data[7])<<8
+	size := bytesWidth * height
+	if len(data) < 8+size {
+		return 0, fmt.Errorf("raster image needs %d bytes but only %d remain", size, len(data)-8)
+	}
+
+	r.flushText()
+
+	dotsWidth := bytesWidth * 8
+	left := r.alignedX(dotsWidth)
+	r.ensureHeight(r.y + height)
+
+	bits := data[8 : 8+size]
+	for row := 0; row < height; row++ {
+		for col := 0; col < dotsWidth; col++ {
+			if bits[row*bytesWidth+col/8]&(0x80>>uint(col%8)) == 0 {
+				continue
+			}
+			x := left + col
+			if x >= 0 && x < r.width {
+				r.img.SetGray(x, r.y+row, dotColor)
+			}
+		}
+	}
+	r.y += height
+
+	return 8 + size, nil
+}
+
+// bitImage draws an ESC * column bit image on the current line and returns the bytes consumed
+func (r *receipt) bitImage(data []byte) (int, error) {
+	if len(data) < 5 {
+		return 0, fmt.Errorf("truncated ESC * header")
+	}
+
+	// Modes 0 and 1 are 8 dots tall, 32 and 33 are 24 dots tall.
+	// Single density modes print every column two dots wide.
+	mode := data[2]
+	columns := int(data[3]) | int(data[4])<<8
+	var rows, dotWidth int
+	switch mode {
+	case 0, 1:
+		rows = 8
+	case 32, 33:
+		rows = 24
+	default:
+		return 0, fmt.Errorf("unsupported ESC * mode %d", mode)
+	}
+	dotWidth = 1
+	if mode == 0 || mode == 32 {
+		dotWidth = 2
+	}
+
+	bytesPerColumn := rows / 8
+	size := columns * bytesPerColumn
+	if len(data) < 5+size {
+		return 0, fmt.Errorf("bit image needs %d bytes but only %d remain", size, len(data)-5)
+	}
+
+	left := r.x
+	if left == 0 {
+		left = r.alignedX(columns * dotWidth)
+	}
+	r.ensureHeight(r.y + rows)
+
+	bits := data[5 : 5+size]
+	for col := 0; col < columns; col++ {
+		for row := 0; row < rows; row++ {
+			if bits[col*bytesPerColumn+row/8]&(0x80>>uint(row%8)) == 0 {
+				continue
+			}
+			for dx := 0; dx < dotWidth; dx++ {
+				x := left + col*dotWidth + dx
+				if x >= 0 && x < r.width {
+					r.img.SetGray(x, r.y+row, dotColor)
+				}
+			}
+		}
+	}
+	r.x = left + columns*dotWidth
+
+	return 5 + size, nil
+}
+
+// text draws a single printable character with a fixed-size font
+func (r *receipt) text(ch byte) {
+	face := basicfont.Face7x13
+	r.ensureHeight(r.y + lineHeight)
+
+	drawer := &font.Drawer{
+		Dst:  r.img,
+		Src:  image.NewUniform(dotColor),
+		Face: face,
+		Dot:  fixed.P(r.x, r.y+face.Ascent+(lineHeight-face.Height)/2),
+	}
+	drawer.DrawString(string(rune(ch)))
+
+	r.x += face.Advance
+	if r.x+face.Advance > r.width {
+		r.feed(lineHeight)
+	}
+}
+
+// flushText moves to a new line if text is pending on the current one
+func (r *receipt) flushText() {
+	if r.x > 0 {
+		r.feed(lineHeight)
+	}
+}
+
+// feed advances the paper by the given number of dots
+func (r *receipt) feed(dots int) {
+	r.x = 0
+	r.y += dots
+	r.ensureHeight(r.y)
+}
+
+// cut draws a dashed cut mark across the paper
+func (r *receipt) cut() {
+	r.flushText()
+	r.ensureHeight(r.y + cutMarkHeight)
+
+	mid := r.y + cutMarkHeight/2
+	for x := 0; x < r.width; x++ {
+		if (x/8)%2 == 0 {
+			r.img.SetGray(x, mid, cutMarkColor)
+			r.img.SetGray(x, mid+1, cutMarkColor)
+		}
+	}
+
+	// Small notches on both edges make the cut easy to spot
+	for d := 0; d < 6; d++ {
+		for x := 0; x < 6-d; x++ {
+			r.img.SetGray(x, mid-d, cutMarkColor)
+			r.img.SetGray(x, mid+1+d, cutMarkColor)
+			r.img.SetGray(r.width-1-x, mid-d, cutMarkColor)
+			r.img.SetGray(r.width-1-x, mid+1+d, cutMarkColor)
+		}
+	}
+
+	r.y += cutMarkHeight
+}
+
+// alignedX returns the left edge of content of the given width for the current justification
+func (r *receipt) alignedX(contentWidth int) int {
+	switch r.align {
+	case 1:
+		return (r.width - contentWidth) / 2
+	case 2:
+		return r.width - contentWidth
+	default:
+		return 0
+	}
+}
+
+// ensureHeight grows the paper so that it is at least the given height
+func (r *receipt) ensureHeight(height int) {
+	current := r.img.Bounds().Dy()
+	if height <= current {
+		return
+	}
+
+	newHeight := current * 2
+	if newHeight < height {
+		newHeight = height
+	}
+	grown := newPaper(r.width, newHeight)
+	draw.Draw(grown, r.img.Bounds(), r.img, image.Point{}, draw.Src)
+	r.img = grown
+}
+
+// image returns the receipt trimmed to the printed length
+func (r *receipt) image() image.Image {
+	height := r.y
+	if height == 0 {
+		height = 1
+	}
+	return r.img.SubImage(image.Rect(0, 0, r.width, height))
+}
+
+// newPaper allocates a blank white sheet
+func newPaper(width, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(paperColor), image.Point{}, draw.Src)
+	return img
+}
@@ -0,0 +1,77 @@
+package emulator
+
+import (
+	"fmt"
+	"time"
+)
+
+// dle starts a real-time command
+const dle = 0x10
+
+// Status is the simulated state of the emulated printer, reported to DLE EOT requests
+type Status struct {
+	Offline      bool `json:"offline"`
+	CoverOpen    bool `json:"cover_open"`
+	PaperOut     bool `json:"paper_out"`
+	PaperNearEnd bool `json:"paper_near_end"`
+}
+
+// SetStatus changes the simulated printer state
+func (e *Emulator) SetStatus(status Status) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.status = status
+}
+
+// Status returns the simulated printer state
+func (e *Emulator) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.status
+}
+
+// Exchange answers DLE EOT real-time status requests like a real printer would
+func (e *Emulator) Exchange(request []byte, replyLen int, timeout time.Duration) ([]byte, error) {
+	status := e.Status()
+
+	var reply []byte
+	for i := 0; i+2 < len(request); i += 3 {
+		if request[i] != dle || request[i+1] != 0x04 {
+			return nil, fmt.Errorf("unsupported real-time request 0x%02x 0x%02x", request[i], request[i+1])
+		}
+		reply = append(reply, statusByte(status, request[i+2]))
+	}
+
+	if len(reply) != replyLen {
+		return nil, fmt.Errorf("request asks for %d replies, expected %d", len(reply), replyLen)
+	}
+	return reply, nil
+}
+
+// statusByte encodes the reply to DLE EOT n. Every reply has bits 1 and 4 set.
+func statusByte(status Status, n byte) byte {
+	b := byte(0x12)
+	switch n {
+	case 1: // Printer status
+		if status.Offline || status.CoverOpen || status.PaperOut {
+			b |= 0x08
+		}
+	case 2: // Offline cause
+		if status.CoverOpen {
+			b |= 0x04
+		}
+		if status.PaperOut {
+			b |= 0x20
+		}
+	case 4: // Paper roll sensor
+		if status.PaperNearEnd || status.PaperOut {
+			b |= 0x0C
+		}
+		if status.PaperOut {
+			b |= 0x60
+		}
+	}
+	return b
+}
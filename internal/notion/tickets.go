@@ -14,6 +14,10 @@ type TicketItem struct {
 	Weekdays string
 	Name     string
 	Assignee string
+	Template string
+	URL      string // Notion page URL
+
+	CooldownFrom string // Whether the cooldown starts at print or completion time
 }
 
 // GetTickets fetches items from Notion database and formats them
@@ -35,6 +39,11 @@ func GetTickets(apiKey, databaseID string) ([]TicketItem, error) {
 
 		ticket := TicketItem{}
 
+		// Extract the page URL, used to link printed tickets back to Notion
+		if url, ok := page["url"].(string); ok {
+			ticket.URL = url
+		}
+
 		// Extract cooldown (rich_text)
 		if cooldownProp, ok := properties["cooldown"].(map[string]interface{}); ok {
 			if richText, ok := cooldownProp["rich_text"].([]interface{}); ok && len(richText) > 0 {
@@ -130,6 +139,36 @@ func GetTickets(apiKey, databaseID string) ([]TicketItem, error) {
 			}
 		}
 
+		// Extract template (select or rich_text)
+		if templateProp, ok := properties["template"].(map[string]interface{}); ok {
+			if selectObj, ok := templateProp["select"].(map[string]interface{}); ok {
+				if name, ok := selectObj["name"].(string); ok {
+					ticket.Template = name
+				}
+			} else if richText, ok := templateProp["rich_text"].([]interface{}); ok && len(richText) > 0 {
+				if textObj, ok := richText[0].(map[string]interface{}); ok {
+					if plainText, ok := textObj["plain_text"].(string); ok {
+						ticket.Template = strings.TrimSpace(plainText)
+					}
+				}
+			}
+		}
+
+		// Extract cooldown_from (select or rich_text)
+		if cooldownFromProp, ok := properties["cooldown_from"].(map[string]interface{}); ok {
+			if selectObj, ok := cooldownFromProp["select"].(map[string]interface{}); ok {
+				if name, ok := selectObj["name"].(string); ok {
+					ticket.CooldownFrom = name
+				}
+			} else if richText, ok := cooldownFromProp["rich_text"].([]interface{}); ok && len(richText) > 0 {
+				if textObj, ok := richText[0].(map[string]interface{}); ok {
+					if plainText, ok := textObj["plain_text"].(string); ok {
+						ticket.CooldownFrom = strings.TrimSpace(plainText)
+					}
+				}
+			}
+		}
+
 		tickets = append(tickets, ticket)
 	}
 
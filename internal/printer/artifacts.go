@@ -0,0 +1,81 @@
+package printer
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Artifacts keeps the intermediate files of a print job for debugging.
+// A nil *Artifacts is valid and saves nothing.
+type Artifacts struct {
+	dir string
+}
+
+// Artifacts returns where the files of a job are kept, or nil when KEEP_ARTIFACTS is off
+func (p *Printer) Artifacts(jobID int) *Artifacts {
+	if !p.keepArtifacts {
+		return nil
+	}
+	return &Artifacts{dir: filepath.Join(p.outputDir, "jobs", fmt.Sprint(jobID))}
+}
+
+// Dir returns the artifacts directory
+func (a *Artifacts) Dir() string {
+	if a == nil {
+		return ""
+	}
+	return a.dir
+}
+
+// SaveFile writes an artifact, logging instead of failing the job when it cannot be written
+func (a *Artifacts) SaveFile(name string, data []byte) {
+	if a == nil {
+		return
+	}
+
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		log.Printf("⚠️  Warning: Failed to create artifacts directory %s: %v", a.dir, err)
+		return
+	}
+
+	path := filepath.Join(a.dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.Printf("⚠️  Warning: Failed to save artifact %s: %v", path, err)
+	}
+}
+
+// ReadFile reads an artifact saved by an earlier run of the job
+func (a *Artifacts) ReadFile(name string) ([]byte, error) {
+	if a == nil {
+		return nil, fmt.Errorf("artifacts are not kept")
+	}
+	return os.ReadFile(filepath.Join(a.dir, name))
+}
+
+// SaveImage writes an image artifact as PNG
+func (a *Artifacts) SaveImage(name string, img image.Image) {
+	if a == nil {
+		return
+	}
+
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		log.Printf("⚠️  Warning: Failed to create artifacts directory %s: %v", a.dir, err)
+		return
+	}
+
+	path := filepath.Join(a.dir, name)
+	file, err := os.Create(path)
+	if err != nil {
+		log.Printf("⚠️  Warning: Failed to save artifact %s: %v", path, err)
+		return
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, img); err != nil {
+		log.Printf("⚠️  Warning: Failed to encode artifact %s: %v", path, err)
+	}
+}
@@ -0,0 +1,85 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PrinterConfig describes a printer registered with the server
+type PrinterConfig struct {
+	Name      string `json:"name"`
+	Transport string `json:"transport"`          // One of the Transport* types, defaults to CUPS
+	Address   string `json:"address"`            // Defaults to the name, which is the CUPS queue
+	Profile   string `json:"profile"`            // Printer profile, defaults to DefaultProfile
+	Fallback  string `json:"fallback,omitempty"` // Printer that takes the jobs this printer cannot deliver
+}
+
+// LoadPrinterConfigs reads the printers from the JSON file of PRINTERS_FILE:
+//
+//	[
+//	  {"name": "office", "transport": "cups", "profile": "pos80", "fallback": "kitchen"},
+//	  {"name": "kitchen", "transport": "tcp", "address": "192.168.1.50", "profile": "pos58"}
+//	]
+//
+// Without PRINTERS_FILE a single printer is built from PRINTER_NAME, PRINTER_TRANSPORT,
+// PRINTER_ADDRESS and PRINTER_PROFILE. The first printer is the default one.
+func LoadPrinterConfigs() ([]PrinterConfig, error) {
+	var configs []PrinterConfig
+
+	path := os.Getenv("PRINTERS_FILE")
+	if path == "" {
+		configs = []PrinterConfig{{
+			Name:      os.Getenv("PRINTER_NAME"),
+			Transport: os.Getenv("PRINTER_TRANSPORT"),
+			Address:   os.Getenv("PRINTER_ADDRESS"),
+			Profile:   os.Getenv("PRINTER_PROFILE"),
+		}}
+	} else {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read printers file: %v", err)
+		}
+		if err := json.Unmarshal(content, &configs); err != nil {
+			return nil, fmt.Errorf("failed to parse printers file %s: %v", path, err)
+		}
+		if len(configs) == 0 {
+			return nil, fmt.Errorf("no printers defined in %s", path)
+		}
+	}
+
+	names := make(map[string]bool)
+	for i := range configs {
+		config := &configs[i]
+		config.Name = strings.TrimSpace(config.Name)
+		config.Fallback = strings.TrimSpace(config.Fallback)
+
+		if config.Name == "" && len(configs) > 1 {
+			return nil, fmt.Errorf("printer %d has no name", i+1)
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("printer %s is defined twice", config.Name)
+		}
+		names[config.Name] = true
+
+		// The transport address defaults to the printer name, which is the CUPS queue
+		if config.Address == "" {
+			config.Address = config.Name
+		}
+	}
+
+	for _, config := range configs {
+		if config.Fallback == "" {
+			continue
+		}
+		if config.Fallback == config.Name {
+			return nil, fmt.Errorf("printer %s cannot be its own fallback", config.Name)
+		}
+		if !names[config.Fallback] {
+			return nil, fmt.Errorf("printer %s has unknown fallback %s", config.Name, config.Fallback)
+		}
+	}
+
+	return configs, nil
+}
@@ -0,0 +1,215 @@
+package printer
+
+import "fmt"
+
+// ESC/POS control bytes
+const (
+	ht  = 0x09
+	lf  = 0x0A
+	cr  = 0x0D
+	dle = 0x10
+	esc = 0x1B
+	fs  = 0x1C
+	gs  = 0x1D
+)
+
+// escArgs lists the ESC commands accepted in raw jobs with their number of argument bytes.
+// They only style text and move the paper forward. Commands that pulse the cash drawer,
+// feed backwards, change panel or sensor settings or select peripherals are left out.
+var escArgs = map[byte]int{
+	'@':  0, // Initialize
+	'2':  0, // Default line spacing
+	'3':  1, // Line spacing
+	' ':  1, // Right-side character spacing
+	'!':  1, // Print mode
+	'-':  1, // Underline
+	'E':  1, // Emphasized
+	'G':  1, // Double-strike
+	'M':  1, // Character font
+	'R':  1, // International character set
+	't':  1, // Character code table
+	'V':  1, // 90 degree rotation
+	'{':  1, // Upside-down
+	'a':  1, // Justification
+	'd':  1, // Print and feed lines
+	'J':  1, // Print and feed dots
+	'$':  2, // Absolute print position
+	'\\': 2, // Relative print position
+}
+
+// gsArgs lists the GS commands accepted in raw jobs with their number of argument bytes
+var gsArgs = map[byte]int{
+	'!': 1, // Character size
+	'B': 1, // White/black reverse
+	'b': 1, // Smoothing
+	'H': 1, // HRI position
+	'f': 1, // HRI font
+	'h': 1, // Barcode height
+	'w': 1, // Barcode width
+	'L': 2, // Left margin
+	'W': 2, // Print area width
+}
+
+// ValidateRaw checks that a raw ESC/POS job sent by a client only uses commands that are
+// safe to forward to the printer. Real-time requests, stored NV data and settings, cash
+// drawer pulses and commands the profile does not support are refused, as well as images
+// wider than the paper.
+func ValidateRaw(data []byte, profile Profile) error {
+	for i := 0; i < len(data); {
+		n, err := rawCommandLength(data[i:], profile)
+		if err != nil {
+			return fmt.Errorf("offset %d: %v", i, err)
+		}
+		i += n
+	}
+	return nil
+}
+
+// rawCommandLength returns the length of the text byte or command at the start of data
+func rawCommandLength(data []byte, profile Profile) (int, error) {
+	b := data[0]
+	switch {
+	case b == esc:
+		return escCommandLength(data, profile)
+	case b == gs:
+		return gsCommandLength(data, profile)
+	case b == dle:
+		return 0, fmt.Errorf("real-time command DLE 0x%02x is not allowed", byteAt(data, 1))
+	case b == fs:
+		return 0, fmt.Errorf("command FS %s is not allowed", commandName(byteAt(data, 1)))
+	case b >= 0x20 || b == lf || b == cr || b == ht:
+		return 1, nil
+	default:
+		return 0, fmt.Errorf("control byte 0x%02x is not allowed", b)
+	}
+}
+
+// escCommandLength returns the length of an allowed ESC command
+func escCommandLength(data []byte, profile Profile) (int, error) {
+	if len(data) < 2 {
+		return 0, fmt.Errorf("truncated ESC command")
+	}
+
+	cmd := data[1]
+	switch cmd {
+	case '*': // Bit image: m nL nH, then one or three bytes per column
+		if !profile.Supports(CommandBitImage) {
+			return 0, fmt.Errorf("ESC * is not supported by profile %s", profile.Name)
+		}
+		if len(data) < 5 {
+			return 0, fmt.Errorf("truncated ESC * command")
+		}
+		columns := int(data[3]) | int(data[4])<<8
+		if columns > profile.WidthDots {
+			return 0, fmt.Errorf("ESC * image is %d dots wide, the paper is %d", columns, profile.WidthDots)
+		}
+		bytesPerColumn := 1
+		if data[2] == 32 || data[2] == 33 {
+			bytesPerColumn = 3
+		}
+		return rawLength(data, 5+columns*bytesPerColumn, "ESC *")
+	case 'i', 'm': // Partial cut
+		if !profile.Cutter {
+			return 0, fmt.Errorf("ESC %c is not allowed, profile %s has no cutter", cmd, profile.Name)
+		}
+		return 2, nil
+	}
+
+	args, ok := escArgs[cmd]
+	if !ok {
+		return 0, fmt.Errorf("command ESC %s is not allowed", commandName(cmd))
+	}
+	return rawLength(data, 2+args, "ESC "+commandName(cmd))
+}
+
+// gsCommandLength returns the length of an allowed GS command
+func gsCommandLength(data []byte, profile Profile) (int, error) {
+	if len(data) < 2 {
+		return 0, fmt.Errorf("truncated GS command")
+	}
+
+	cmd := data[1]
+	switch cmd {
+	case 'v': // Raster image: 0 m xL xH yL yH, then xL+xH*256 bytes per row
+		if !profile.Supports(CommandRaster) {
+			return 0, fmt.Errorf("GS v 0 is not supported by profile %s", profile.Name)
+		}
+		if len(data) < 8 || data[2] != '0' {
+			return 0, fmt.Errorf("truncated GS v 0 command")
+		}
+		bytesWidth := int(data[4]) | int(data[5])<<8
+		height := int(data[6]) | int(data[7])<<8
+		if bytesWidth*8 > (profile.WidthDots+7)/8*8 {
+			return 0, fmt.Errorf("GS v 0 image is %d dots wide, the paper is %d", bytesWidth*8, profile.WidthDots)
+		}
+		return rawLength(data, 8+bytesWidth*height, "GS v 0")
+	case 'V': // Cut, functions B carry a feed amount
+		if !profile.Cutter {
+			return 0, fmt.Errorf("GS V is not allowed, profile %s has no cutter", profile.Name)
+		}
+		if len(data) < 3 {
+			return 0, fmt.Errorf("truncated GS V command")
+		}
+		if data[2] >= 'A' {
+			return rawLength(data, 4, "GS V")
+		}
+		return 3, nil
+	case 'k': // Barcode: NUL terminated data for m 0-6, length prefixed data for m 65-79
+		if len(data) < 3 {
+			return 0, fmt.Errorf("truncated GS k command")
+		}
+		if data[2] <= 6 {
+			for i := 3; i < len(data) && i < 3+256; i++ {
+				if data[i] == 0 {
+					return i + 1, nil
+				}
+			}
+			return 0, fmt.Errorf("unterminated GS k barcode")
+		}
+		if len(data) < 4 {
+			return 0, fmt.Errorf("truncated GS k command")
+		}
+		return rawLength(data, 4+int(data[3]), "GS k")
+	case '(': // Function commands, only GS ( k symbols such as QR codes are allowed
+		if len(data) < 5 {
+			return 0, fmt.Errorf("truncated GS ( command")
+		}
+		if data[2] != 'k' {
+			return 0, fmt.Errorf("command GS ( %s is not allowed", commandName(data[2]))
+		}
+		if !profile.Supports(CommandQRCode) {
+			return 0, fmt.Errorf("GS ( k is not supported by profile %s", profile.Name)
+		}
+		return rawLength(data, 5+(int(data[3])|int(data[4])<<8), "GS ( k")
+	}
+
+	args, ok := gsArgs[cmd]
+	if !ok {
+		return 0, fmt.Errorf("command GS %s is not allowed", commandName(cmd))
+	}
+	return rawLength(data, 2+args, "GS "+commandName(cmd))
+}
+
+// rawLength checks that a command of the given length is complete
+func rawLength(data []byte, length int, name string) (int, error) {
+	if len(data) < length {
+		return 0, fmt.Errorf("truncated %s command", name)
+	}
+	return length, nil
+}
+
+// byteAt returns the byte at index i, or 0 past the end of data
+func byteAt(data []byte, i int) byte {
+	if i < len(data) {
+		return data[i]
+	}
+	return 0
+}
+
+// commandName prints a command byte as a character when it is printable
+func commandName(b byte) string {
+	if b > 0x20 && b < 0x7F {
+		return string(rune(b))
+	}
+	return fmt.Sprintf("0x%02x", b)
+}
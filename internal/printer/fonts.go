@@ -0,0 +1,174 @@
+package printer
+
+import (
+	"encoding/base64"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+)
+
+// fontCache keeps parsed fonts by family and weight
+var fontCache = struct {
+	sync.Mutex
+	fonts map[string]*truetype.Font
+}{fonts: make(map[string]*truetype.Font)}
+
+// loadFont returns the font for a family, looking for TTF files in the fonts directory
+// next to the executable (Family.ttf, Family-Regular.ttf or Family-Bold.ttf).
+// It falls back to the built-in Go fonts when the family is not installed.
+func loadFont(family string, bold bool) *truetype.Font {
+	family = fontFamilyName(family)
+	key := fontKey(family, bold)
+
+	fontCache.Lock()
+	defer fontCache.Unlock()
+
+	if f, ok := fontCache.fonts[key]; ok {
+		return f
+	}
+
+	f := findFontFile(family, bold)
+	if f == nil {
+		fallback := goregular.TTF
+		if bold {
+			fallback = gobold.TTF
+		}
+		f, _ = truetype.Parse(fallback)
+	}
+
+	fontCache.fonts[key] = f
+	return f
+}
+
+// findFontFile parses the first matching TTF file in the fonts directory
+func findFontFile(family string, bold bool) *truetype.Font {
+	if family == "" {
+		return nil
+	}
+
+	fontsDir, err := GetExecutableRelativePath("fonts")
+	if err != nil {
+		return nil
+	}
+
+	candidates := []string{family + "-Regular.ttf", family + ".ttf"}
+	if bold {
+		candidates = []string{family + "-Bold.ttf", family + "Bold.ttf", family + ".ttf"}
+	}
+
+	for _, name := range candidates {
+		content, err := os.ReadFile(filepath.Join(fontsDir, name))
+		if err != nil {
+			continue
+		}
+
+		f, err := truetype.Parse(content)
+		if err != nil {
+			log.Printf("⚠️  Warning: Failed to parse font %s: %v", name, err)
+			continue
+		}
+		return f
+	}
+
+	return nil
+}
+
+// fontSet resolves fonts, preferring fonts embedded in the SVG over installed ones
+type fontSet map[string]*truetype.Font
+
+// Patterns for @font-face rules with a base64 data URL source
+var (
+	fontFacePattern   = regexp.MustCompile(`(?s)@font-face\s*\{([^}]*)\}`)
+	fontFamilyPattern = regexp.MustCompile(`font-family\s*:\s*([^;]+)`)
+	fontWeightPattern = regexp.MustCompile(`font-weight\s*:\s*([^;]+)`)
+	fontSourcePattern = regexp.MustCompile(`url\(\s*["']?data:[^;,]*;base64,([A-Za-z0-9+/=\s]+)["']?\s*\)`)
+)
+
+// embeddedFonts parses the TrueType fonts embedded with @font-face in an SVG document
+func embeddedFonts(svgContent string) fontSet {
+	fonts := make(fontSet)
+	for _, match := range fontFacePattern.FindAllStringSubmatch(svgContent, -1) {
+		rule := match[1]
+
+		family := fontFamilyPattern.FindStringSubmatch(rule)
+		source := fontSourcePattern.FindStringSubmatch(rule)
+		if family == nil || source == nil {
+			continue
+		}
+
+		content, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(source[1]), ""))
+		if err != nil {
+			log.Printf("⚠️  Warning: Failed to decode embedded font %s: %v", family[1], err)
+			continue
+		}
+
+		f, err := truetype.Parse(content)
+		if err != nil {
+			log.Printf("⚠️  Warning: Failed to parse embedded font %s: %v", family[1], err)
+			continue
+		}
+
+		bold := false
+		if weight := fontWeightPattern.FindStringSubmatch(rule); weight != nil {
+			bold = isBold(strings.TrimSpace(weight[1]))
+		}
+		fonts[fontKey(fontFamilyName(family[1]), bold)] = f
+	}
+	return fonts
+}
+
+// font returns the embedded font for a family, or the installed one when it is not embedded
+func (fs fontSet) font(family string, bold bool) *truetype.Font {
+	if f, ok := fs[fontKey(fontFamilyName(family), bold)]; ok {
+		return f
+	}
+	return loadFont(family, bold)
+}
+
+// fontFamilyName returns the first family of a CSS font-family list without quotes
+func fontFamilyName(family string) string {
+	return strings.Trim(strings.TrimSpace(strings.Split(family, ",")[0]), `"'`)
+}
+
+// fontKey identifies a font by family and weight
+func fontKey(family string, bold bool) string {
+	if bold {
+		return family + "-bold"
+	}
+	return family
+}
+
+// isBold reports whether a CSS font-weight is bold
+func isBold(weight string) bool {
+	numericWeight, _ := strconv.Atoi(weight)
+	return weight == "bold" || weight == "bolder" || numericWeight >= 600
+}
+
+// newFace creates a font face where the size is given in SVG user units (pixels)
+func newFace(f *truetype.Font, size float64) font.Face {
+	return truetype.NewFace(f, &truetype.Options{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingNone,
+	})
+}
+
+// measureText returns the advance width of text in pixels
+func measureText(face font.Face, text string) float64 {
+	return fixedToFloat(font.MeasureString(face, text))
+}
+
+// fixedToFloat converts a 26.6 fixed point value to a float
+func fixedToFloat(v fixed.Int26_6) float64 {
+	return float64(v) / 64
+}
@@ -1,131 +1,151 @@
 package printer
 
 import (
+	"bytes"
 	"fmt"
 	"image"
-	"image/color"
-	"io"
-	"os"
-	"os/exec"
-
-	"github.com/cloudinn/escpos"
-	"github.com/cloudinn/escpos/raster"
+	"sync"
+	"time"
 )
 
 // ImagePrinter handles printing images to the printer
 type ImagePrinter struct {
-	printerName string
+	transport Transport
+	profile   Profile
+	mu        sync.Mutex // Keeps concurrent jobs from interleaving on the device
 }
 
-// NewImagePrinter creates a new image printer
-func NewImagePrinter(printerName string) *ImagePrinter {
+// NewImagePrinter creates a new image printer that encodes jobs for the given profile
+// and sends them over the given transport
+func NewImagePrinter(transport Transport, profile Profile) *ImagePrinter {
 	return &ImagePrinter{
-		printerName: printerName,
+		transport: transport,
+		profile:   profile,
 	}
 }
 
-// PrintImage prints an image using CloudInn/escpos library via lp command
-func (ip *ImagePrinter) PrintImage(imagePath string, printerName string) error {
-	// Check if the image file exists
-	if _, err := os.Stat(imagePath); os.IsNotExist(err) {
-		return fmt.Errorf("image file does not exist: %s", imagePath)
-	}
-
-	// Use lp command with raw output for ESC/POS printing
-	cmd := exec.Command("lp", "-d", printerName, "-o", "raw")
-	stdin, err := cmd.StdinPipe()
+// PrintImage encodes an image and the native QR codes below it as ESC/POS and sends it
+// over the transport. Encoding runs concurrently, sending is serialized. It returns the
+// spooler job ID when the transport hands jobs to a spooler, and an empty string otherwise.
+func (ip *ImagePrinter) PrintImage(img image.Image, opts RasterOptions, codes []QRCode, artifacts *Artifacts, onStage StageFunc) (string, error) {
+	var data []byte
+	err := runStage(onStage, StageEncode, func() error {
+		var err error
+		data, err = EncodeImage(img, ip.profile, opts, codes)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create stdin pipe: %v", err)
+		return "", err
 	}
+	artifacts.SaveFile("job.escpos", data)
 
-	// Load the image
-	imageFile, err := os.Open(imagePath)
-	if err != nil {
-		stdin.Close()
-		return fmt.Errorf("failed to open image file: %v", err)
+	return ip.send(data, onStage)
+}
+
+// PrintRaw sends ESC/POS data that is already encoded, such as a job received from
+// another application, after the jobs being sent
+func (ip *ImagePrinter) PrintRaw(data []byte, artifacts *Artifacts, onStage StageFunc) (string, error) {
+	artifacts.SaveFile("job.escpos", data)
+
+	return ip.send(data, onStage)
+}
+
+// send writes a job to the transport and returns the spooler job ID, if any
+func (ip *ImagePrinter) send(data []byte, onStage StageFunc) (string, error) {
+	var spoolJobID string
+	err := runStage(onStage, StageSend, func() error {
+		ip.mu.Lock()
+		defer ip.mu.Unlock()
+
+		var err error
+		if spooler, ok := ip.transport.(SpoolTransport); ok {
+			spoolJobID, err = spooler.Submit(data)
+		} else {
+			err = ip.transport.Send(data)
+		}
+		if err != nil {
+			return &TransportError{Err: err}
+		}
+		return nil
+	})
+	return spoolJobID, err
+}
+
+// SupportsStatus reports whether the printer and its transport can report a status
+func (ip *ImagePrinter) SupportsStatus() bool {
+	if _, ok := ip.transport.(StatusReporter); ok {
+		return true
 	}
-	defer imageFile.Close()
+	_, ok := ip.transport.(StatusTransport)
+	return ok && ip.profile.Supports(CommandStatus)
+}
 
-	// Decode the image (supports PNG, JPEG, GIF)
-	img, _, err := image.Decode(imageFile)
-	if err != nil {
-		stdin.Close()
-		return fmt.Errorf("failed to decode image: %v", err)
+// Status asks the printer for its real-time status. It waits for any job being sent,
+// since the reply would otherwise be mixed with the job data.
+func (ip *ImagePrinter) Status() (Status, error) {
+	if !ip.SupportsStatus() {
+		return Status{Supported: false, CheckedAt: time.Now()}, nil
 	}
 
-	img = invertColors(img)
+	// Spoolers answer on their own connection, so their jobs do not need to be waited for
+	if reporter, ok := ip.transport.(StatusReporter); ok {
+		status, err := reporter.PrinterStatus()
+		if err != nil {
+			return status, fmt.Errorf("failed to read printer status: %v", err)
+		}
+		return status, nil
+	}
+	statusTransport := ip.transport.(StatusTransport)
 
-	// Create a wrapper to make stdin compatible with io.ReadWriter
-	readWriter := &readWriterWrapper{writer: stdin}
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
 
-	// Create ESC/POS printer instance
-	ep, err := escpos.NewPrinter(readWriter)
+	replies, err := statusTransport.Exchange(statusRequest, len(statusRequest)/3, StatusTimeout)
 	if err != nil {
-		stdin.Close()
-		return fmt.Errorf("failed to create printer: %v", err)
+		return Status{Supported: true, CheckedAt: time.Now()}, fmt.Errorf("failed to read printer status: %v", err)
 	}
 
-	// Initialize printer
-	ep.Init()
-	ep.SetAlign("center")
+	return parseStatus(replies)
+}
 
-	// Create raster converter with Pi Zero 2W optimizations
-	rasterConv := &raster.Converter{
-		MaxWidth:  512, // Standard thermal printer width
-		Threshold: 0.5, // Black/white threshold
+// EncodeImage converts an image into the ESC/POS byte stream shared by all transports,
+// using the image commands, feed and cut of the printer profile. Native QR codes are
+// printed centered below the image.
+func EncodeImage(img image.Image, profile Profile, opts RasterOptions, codes []QRCode) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if len(codes) > 0 && !profile.Supports(CommandQRCode) {
+		return nil, fmt.Errorf("profile %s does not support native QR codes", profile.Name)
 	}
 
-	// Print the image using the raster converter
-	rasterConv.Print(img, ep)
+	var buf bytes.Buffer
 
-	// Add some spacing and cut
-	ep.Linefeed()
-	ep.Cut()
-	ep.End()
+	// Initialize printer and center the image
+	buf.Write([]byte{0x1B, '@'})
+	buf.Write([]byte{0x1B, 'a', 1})
 
-	// Close stdin and run the command
-	stdin.Close()
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("lp command failed: %v", err)
+	if profile.Supports(CommandRaster) {
+		buf.Write(EncodeRaster(img, profile.WidthDots, opts))
+	} else {
+		buf.Write(EncodeBitImage(img, profile.WidthDots, opts))
 	}
 
-	return nil
-}
-
-// invertColors inverts the colors of an image for thermal printing
-func invertColors(img image.Image) image.Image {
-	bounds := img.Bounds()
-	inverted := image.NewRGBA(bounds)
-
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			originalColor := img.At(x, y)
-			r, g, b, a := originalColor.RGBA()
-
-			// Invert RGB values (255 - value)
-			invertedColor := color.RGBA{
-				R: 255 - uint8(r>>8),
-				G: 255 - uint8(g>>8),
-				B: 255 - uint8(b>>8),
-				A: uint8(a >> 8),
-			}
-			inverted.Set(x, y, invertedColor)
+	for _, code := range codes {
+		data, err := EncodeQRCode(code)
+		if err != nil {
+			return nil, err
 		}
+		buf.Write(data)
 	}
 
-	return inverted
-}
-
-// readWriterWrapper makes io.WriteCloser compatible with io.ReadWriter
-type readWriterWrapper struct {
-	writer io.WriteCloser
-}
-
-func (r *readWriterWrapper) Write(p []byte) (n int, err error) {
-	return r.writer.Write(p)
-}
+	// Feed the paper past the cutter or tear bar, then cut if the printer can
+	if profile.FeedLines > 0 {
+		buf.Write([]byte{0x1B, 'd', byte(profile.FeedLines)})
+	}
+	if profile.Cutter {
+		buf.Write([]byte{0x1D, 'V', 'A', 0})
+	}
 
-func (r *readWriterWrapper) Read(p []byte) (n int, err error) {
-	// For ESC/POS printing, we only need to write, not read
-	return 0, io.EOF
+	return buf.Bytes(), nil
 }
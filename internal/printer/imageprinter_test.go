@@ -0,0 +1,49 @@
+package printer
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPrintImageMemoryTransport(t *testing.T) {
+	// 16x2 image, black on the left half and white on the right half
+	img := image.NewGray(image.Rect(0, 0, 16, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 16; x++ {
+			img.SetGray(x, y, color.Gray{Y: 0xFF})
+			if x < 8 {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			}
+		}
+	}
+
+	transport := &MemoryTransport{}
+	profile := Profile{Name: "test", WidthDots: 16, DPI: 203, Commands: []string{CommandRaster}}
+	ip := NewImagePrinter(transport, profile)
+
+	spoolJobID, err := ip.PrintImage(img, RasterOptions{Dither: DitherThreshold}, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("PrintImage: %v", err)
+	}
+	if spoolJobID != "" {
+		t.Errorf("spool job ID = %q, want none for a memory transport", spoolJobID)
+	}
+
+	jobs := transport.Jobs()
+	if len(jobs) != 1 {
+		t.Fatalf("got %d jobs, want 1", len(jobs))
+	}
+
+	want := []byte{
+		0x1B, '@', // Initialize
+		0x1B, 'a', 1, // Center
+		0x1D, 'v', '0', 0, 2, 0, 2, 0, // GS v 0, 2 bytes wide, 2 lines
+		0xFF, 0x00,
+		0xFF, 0x00,
+	}
+	if !bytes.Equal(jobs[0], want) {
+		t.Errorf("job = % X\nwant  % X", jobs[0], want)
+	}
+}
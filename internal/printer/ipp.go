@@ -0,0 +1,578 @@
+package printer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// IPP operations used by the client
+const (
+	ippPrintJob             uint16 = 0x0002
+	ippCancelJob            uint16 = 0x0008
+	ippGetJobAttributes     uint16 = 0x0009
+	ippGetPrinterAttributes uint16 = 0x000B
+)
+
+// IPP attribute group delimiters
+const (
+	ippTagOperation byte = 0x01
+	ippTagJob       byte = 0x02
+	ippTagEnd       byte = 0x03
+	ippTagPrinter   byte = 0x04
+)
+
+// IPP value tags
+const (
+	ippTagInteger      byte = 0x21
+	ippTagBoolean      byte = 0x22
+	ippTagEnum         byte = 0x23
+	ippTagTextLang     byte = 0x35
+	ippTagNameLang     byte = 0x36
+	ippTagName         byte = 0x42
+	ippTagKeyword      byte = 0x44
+	ippTagURI          byte = 0x45
+	ippTagCharset      byte = 0x47
+	ippTagLanguage     byte = 0x48
+	ippTagMimeType     byte = 0x49
+	ippTagOutOfBandMax byte = 0x1F // Tags up to this one carry no value, such as no-value or unknown
+)
+
+// IPPRawFormat is the document format CUPS passes to the printer untouched
+const IPPRawFormat = "application/vnd.cups-raw"
+
+// DefaultIPPPort is the port of ipp:// URIs that do not set one
+const DefaultIPPPort = "631"
+
+// IPP status codes that the client reports by name
+var ippStatusNames = map[uint16]string{
+	0x0000: "successful-ok",
+	0x0001: "successful-ok-ignored-or-substituted-attributes",
+	0x0002: "successful-ok-conflicting-attributes",
+	0x0400: "client-error-bad-request",
+	0x0401: "client-error-forbidden",
+	0x0402: "client-error-not-authenticated",
+	0x0403: "client-error-not-authorized",
+	0x0404: "client-error-not-possible",
+	0x0405: "client-error-timeout",
+	0x0406: "client-error-not-found",
+	0x0407: "client-error-gone",
+	0x0408: "client-error-request-entity-too-large",
+	0x040A: "client-error-document-format-not-supported",
+	0x040B: "client-error-attributes-or-values-not-supported",
+	0x0500: "server-error-internal-error",
+	0x0501: "server-error-operation-not-supported",
+	0x0502: "server-error-service-unavailable",
+	0x0503: "server-error-version-not-supported",
+	0x0504: "server-error-device-error",
+	0x0505: "server-error-temporary-error",
+	0x0506: "server-error-not-accepting-jobs",
+	0x0507: "server-error-busy",
+	0x0508: "server-error-job-canceled",
+}
+
+// IPPError is an IPP response whose status code reports a failure
+type IPPError struct {
+	Operation string
+	Status    uint16
+	Message   string // status-message sent by the server, may be empty
+}
+
+func (e *IPPError) Error() string {
+	name, ok := ippStatusNames[e.Status]
+	if !ok {
+		name = "unknown-status"
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("ipp %s failed: %s (0x%04x): %s", e.Operation, name, e.Status, e.Message)
+	}
+	return fmt.Sprintf("ipp %s failed: %s (0x%04x)", e.Operation, name, e.Status)
+}
+
+// Temporary reports whether the request may succeed when sent again later, such as when
+// the printer is busy. Other errors, such as an unknown queue, fail the same way every time.
+func (e *IPPError) Temporary() bool {
+	switch e.Status {
+	case 0x0405, 0x0500, 0x0502, 0x0504, 0x0505, 0x0506, 0x0507:
+		return true
+	}
+	return false
+}
+
+// NotFound reports whether the printer or job does not exist
+func (e *IPPError) NotFound() bool {
+	return e.Status == 0x0406 || e.Status == 0x0407
+}
+
+// IPPHTTPError is an HTTP error status answered to an IPP request, such as 404 for an
+// unknown printer path or 401 when authentication is required
+type IPPHTTPError struct {
+	Operation  string
+	Endpoint   string
+	StatusCode int
+	Status     string // Status line, such as "404 Not Found"
+}
+
+func (e *IPPHTTPError) Error() string {
+	return fmt.Sprintf("ipp %s request to %s failed: HTTP %s", e.Operation, e.Endpoint, e.Status)
+}
+
+// Temporary reports whether the server failed on its side, client errors fail the same way every time
+func (e *IPPHTTPError) Temporary() bool {
+	return e.StatusCode >= 500
+}
+
+// PrinterAttributes is the answer to Get-Printer-Attributes
+type PrinterAttributes struct {
+	State         string   `json:"state"`         // idle, processing or stopped
+	StateReasons  []string `json:"state_reasons"` // printer-state-reasons keywords such as media-empty-error
+	StateMessage  string   `json:"state_message,omitempty"`
+	AcceptingJobs bool     `json:"accepting_jobs"`
+	MakeAndModel  string   `json:"make_and_model,omitempty"`
+}
+
+// JobAttributes is the answer to Get-Job-Attributes
+type JobAttributes struct {
+	ID           int        `json:"id"`
+	State        SpoolState `json:"state"`
+	StateReasons []string   `json:"state_reasons"`
+	StateMessage string     `json:"state_message,omitempty"`
+}
+
+// IPPClient talks IPP/1.1 to a printer queue, such as a CUPS queue at ipp://host:631/printers/Office
+type IPPClient struct {
+	URI        string // Printer URI sent in every request
+	User       string // requesting-user-name
+	HTTPClient *http.Client
+
+	endpoint  string // HTTP URL the requests are posted to
+	requestID atomic.Int32
+}
+
+// NewIPPClient creates a client for the printer URI. ipp:// and ipps:// URIs are posted
+// over HTTP and HTTPS on port 631 unless the URI sets a port, http:// and https:// as is.
+func NewIPPClient(uri string) (*IPPClient, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid printer uri %q: %v", uri, err)
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("invalid printer uri %q: missing host", uri)
+	}
+
+	endpoint := *parsed
+	switch parsed.Scheme {
+	case "ipp", "ipps":
+		endpoint.Scheme = "http"
+		if parsed.Scheme == "ipps" {
+			endpoint.Scheme = "https"
+		}
+		if parsed.Port() == "" {
+			endpoint.Host = parsed.Host + ":" + DefaultIPPPort
+		}
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("invalid printer uri %q: unsupported scheme %s", uri, parsed.Scheme)
+	}
+
+	return &IPPClient{
+		URI:        uri,
+		User:       "printy",
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		endpoint:   endpoint.String(),
+	}, nil
+}
+
+// PrintJob submits raw data to the printer and returns the job ID
+func (c *IPPClient) PrintJob(jobName string, data []byte) (int, error) {
+	req := c.newRequest(ippPrintJob)
+	req.add(ippTagName, "job-name", jobName)
+	req.add(ippTagMimeType, "document-format", IPPRawFormat)
+
+	resp, err := c.do("Print-Job", req, data)
+	if err != nil {
+		return 0, err
+	}
+
+	jobID, ok := resp.int(ippTagJob, "job-id")
+	if !ok {
+		return 0, fmt.Errorf("ipp Print-Job response has no job-id")
+	}
+	return jobID, nil
+}
+
+// CancelJob cancels a job that has not finished yet. Jobs the printer does not know
+// return an *IPPError whose NotFound reports true.
+func (c *IPPClient) CancelJob(jobID int) error {
+	req := c.newRequest(ippCancelJob)
+	req.add(ippTagInteger, "job-id", jobID)
+
+	_, err := c.do("Cancel-Job", req, nil)
+	return err
+}
+
+// GetPrinterAttributes returns the state of the printer
+func (c *IPPClient) GetPrinterAttributes() (*PrinterAttributes, error) {
+	req := c.newRequest(ippGetPrinterAttributes)
+	req.add(ippTagKeyword, "requested-attributes",
+		"printer-state", "printer-state-reasons", "printer-state-message",
+		"printer-is-accepting-jobs", "printer-make-and-model")
+
+	resp, err := c.do("Get-Printer-Attributes", req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := &PrinterAttributes{
+		StateReasons: stateReasons(resp.strings(ippTagPrinter, "printer-state-reasons")),
+		StateMessage: resp.string(ippTagPrinter, "printer-state-message"),
+		MakeAndModel: resp.string(ippTagPrinter, "printer-make-and-model"),
+	}
+	attrs.AcceptingJobs, _ = resp.bool(ippTagPrinter, "printer-is-accepting-jobs")
+
+	state, _ := resp.int(ippTagPrinter, "printer-state")
+	switch state {
+	case 3:
+		attrs.State = "idle"
+	case 4:
+		attrs.State = "processing"
+	case 5:
+		attrs.State = "stopped"
+	default:
+		attrs.State = "unknown"
+	}
+
+	return attrs, nil
+}
+
+// GetJobAttributes returns the state of a job
+func (c *IPPClient) GetJobAttributes(jobID int) (*JobAttributes, error) {
+	req := c.newRequest(ippGetJobAttributes)
+	req.add(ippTagInteger, "job-id", jobID)
+	req.add(ippTagKeyword, "requested-attributes", "job-id", "job-state", "job-state-reasons", "job-state-message")
+
+	resp, err := c.do("Get-Job-Attributes", req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := &JobAttributes{
+		ID:           jobID,
+		StateReasons: stateReasons(resp.strings(ippTagJob, "job-state-reasons")),
+		StateMessage: resp.string(ippTagJob, "job-state-message"),
+	}
+
+	state, _ := resp.int(ippTagJob, "job-state")
+	switch state {
+	case 3:
+		attrs.State = SpoolPending
+	case 4:
+		attrs.State = SpoolHeld
+	case 5, 6: // processing-stopped resumes once the printer recovers
+		attrs.State = SpoolProcessing
+	case 7:
+		attrs.State = SpoolCancelled
+	case 8:
+		attrs.State = SpoolAborted
+	case 9:
+		attrs.State = SpoolCompleted
+	default:
+		attrs.State = SpoolUnknown
+	}
+
+	return attrs, nil
+}
+
+// stateReasons drops the "none" keyword sent when there is nothing to report
+func stateReasons(reasons []string) []string {
+	var result []string
+	for _, reason := range reasons {
+		if reason != "none" {
+			result = append(result, reason)
+		}
+	}
+	return result
+}
+
+// newRequest starts a request with the operation attributes every request needs
+func (c *IPPClient) newRequest(operation uint16) *ippMessage {
+	req := &ippMessage{
+		code:      operation,
+		requestID: c.requestID.Add(1),
+	}
+	req.add(ippTagCharset, "attributes-charset", "utf-8")
+	req.add(ippTagLanguage, "attributes-natural-language", "en")
+	req.add(ippTagURI, "printer-uri", c.URI)
+	req.add(ippTagName, "requesting-user-name", c.User)
+	return req
+}
+
+// do posts a request followed by the document data and decodes the response.
+// Responses with an error status code are returned as *IPPError, HTTP errors as *IPPHTTPError.
+func (c *IPPClient) do(operation string, req *ippMessage, document []byte) (*ippMessage, error) {
+	body := io.MultiReader(bytes.NewReader(req.encode()), bytes.NewReader(document))
+	httpReq, err := http.NewRequest(http.MethodPost, c.endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ipp request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/ipp")
+
+	httpResp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ipp %s request to %s failed: %v", operation, c.endpoint, err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, &IPPHTTPError{
+			Operation:  operation,
+			Endpoint:   c.endpoint,
+			StatusCode: httpResp.StatusCode,
+			Status:     httpResp.Status,
+		}
+	}
+
+	resp, err := decodeIPP(bufio.NewReader(httpResp.Body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ipp %s response: %v", operation, err)
+	}
+	if resp.requestID != req.requestID {
+		return nil, fmt.Errorf("invalid ipp %s response: request id %d, expected %d", operation, resp.requestID, req.requestID)
+	}
+	if resp.code >= 0x0100 {
+		return nil, &IPPError{
+			Operation: operation,
+			Status:    resp.code,
+			Message:   resp.string(ippTagOperation, "status-message"),
+		}
+	}
+
+	return resp, nil
+}
+
+// ippAttribute is a named attribute with one or more values
+type ippAttribute struct {
+	tag    byte // Value tag of the first value
+	name   string
+	values []interface{} // int, bool or string depending on the tag
+}
+
+// ippGroup is an attribute group such as the operation or printer attributes
+type ippGroup struct {
+	tag        byte
+	attributes []ippAttribute
+}
+
+// ippMessage is an IPP request or response. The code is the operation of a
+// request and the status code of a response.
+type ippMessage struct {
+	code      uint16
+	requestID int32
+	groups    []ippGroup
+}
+
+// add appends an attribute to the operation group of a request
+func (m *ippMessage) add(tag byte, name string, values ...interface{}) {
+	if len(m.groups) == 0 {
+		m.groups = append(m.groups, ippGroup{tag: ippTagOperation})
+	}
+	group := &m.groups[len(m.groups)-1]
+	group.attributes = append(group.attributes, ippAttribute{tag: tag, name: name, values: values})
+}
+
+// encode writes the message in the IPP/1.1 binary format
+func (m *ippMessage) encode() []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{1, 1}) // Version 1.1
+	binary.Write(&buf, binary.BigEndian, m.code)
+	binary.Write(&buf, binary.BigEndian, m.requestID)
+
+	for _, group := range m.groups {
+		buf.WriteByte(group.tag)
+		for _, attr := range group.attributes {
+			for i, value := range attr.values {
+				name := attr.name
+				if i > 0 {
+					name = "" // Additional values of the same attribute have no name
+				}
+				buf.WriteByte(attr.tag)
+				binary.Write(&buf, binary.BigEndian, uint16(len(name)))
+				buf.WriteString(name)
+
+				var encoded []byte
+				switch v := value.(type) {
+				case int:
+					encoded = binary.BigEndian.AppendUint32(nil, uint32(int32(v)))
+				case bool:
+					encoded = []byte{0}
+					if v {
+						encoded[0] = 1
+					}
+				case string:
+					encoded = []byte(v)
+				}
+				binary.Write(&buf, binary.BigEndian, uint16(len(encoded)))
+				buf.Write(encoded)
+			}
+		}
+	}
+	buf.WriteByte(ippTagEnd)
+
+	return buf.Bytes()
+}
+
+// decodeIPP reads a message in the IPP binary format, up to the end of the attributes
+func decodeIPP(r io.Reader) (*ippMessage, error) {
+	var header struct {
+		Version   [2]byte
+		Code      uint16
+		RequestID int32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("failed to read header: %v", err)
+	}
+	if header.Version[0] < 1 || header.Version[0] > 2 {
+		return nil, fmt.Errorf("unsupported version %d.%d", header.Version[0], header.Version[1])
+	}
+
+	m := &ippMessage{code: header.Code, requestID: header.RequestID}
+	var group *ippGroup
+	for {
+		var tag [1]byte
+		if _, err := io.ReadFull(r, tag[:]); err != nil {
+			return nil, fmt.Errorf("failed to read tag: %v", err)
+		}
+
+		if tag[0] == ippTagEnd {
+			return m, nil
+		}
+		if tag[0] < 0x10 {
+			m.groups = append(m.groups, ippGroup{tag: tag[0]})
+			group = &m.groups[len(m.groups)-1]
+			continue
+		}
+		if group == nil {
+			return nil, fmt.Errorf("attribute outside of a group")
+		}
+
+		name, err := readIPPField(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attribute name: %v", err)
+		}
+		raw, err := readIPPField(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read value of %s: %v", name, err)
+		}
+
+		var value interface{}
+		switch {
+		case tag[0] <= ippTagOutOfBandMax:
+			value = nil
+		case tag[0] == ippTagInteger || tag[0] == ippTagEnum:
+			if len(raw) != 4 {
+				return nil, fmt.Errorf("invalid integer value of %s", name)
+			}
+			value = int(int32(binary.BigEndian.Uint32(raw)))
+		case tag[0] == ippTagBoolean:
+			if len(raw) != 1 {
+				return nil, fmt.Errorf("invalid boolean value of %s", name)
+			}
+			value = raw[0] != 0
+		case tag[0] == ippTagTextLang || tag[0] == ippTagNameLang:
+			// The natural language comes first, both parts are prefixed with their length
+			if len(raw) < 2 || int(binary.BigEndian.Uint16(raw))+4 > len(raw) {
+				return nil, fmt.Errorf("invalid text value of %s", name)
+			}
+			raw = raw[2+binary.BigEndian.Uint16(raw):]
+			value = string(raw[2:])
+		default:
+			value = string(raw)
+		}
+
+		if len(name) == 0 {
+			// Additional value of the previous attribute
+			if len(group.attributes) == 0 {
+				return nil, fmt.Errorf("additional value without an attribute")
+			}
+			last := &group.attributes[len(group.attributes)-1]
+			last.values = append(last.values, value)
+			continue
+		}
+		group.attributes = append(group.attributes, ippAttribute{tag: tag[0], name: string(name), values: []interface{}{value}})
+	}
+}
+
+// readIPPField reads a field prefixed with its 16-bit length
+func readIPPField(r io.Reader) ([]byte, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	field := make([]byte, length)
+	if _, err := io.ReadFull(r, field); err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+// values returns the values of the first attribute with the name in a group with the tag
+func (m *ippMessage) values(groupTag byte, name string) []interface{} {
+	for _, group := range m.groups {
+		if group.tag != groupTag {
+			continue
+		}
+		for _, attr := range group.attributes {
+			if attr.name == name {
+				return attr.values
+			}
+		}
+	}
+	return nil
+}
+
+// int returns the first value of an integer or enum attribute
+func (m *ippMessage) int(groupTag byte, name string) (int, bool) {
+	values := m.values(groupTag, name)
+	if len(values) == 0 {
+		return 0, false
+	}
+	v, ok := values[0].(int)
+	return v, ok
+}
+
+// bool returns the first value of a boolean attribute
+func (m *ippMessage) bool(groupTag byte, name string) (bool, bool) {
+	values := m.values(groupTag, name)
+	if len(values) == 0 {
+		return false, false
+	}
+	v, ok := values[0].(bool)
+	return v, ok
+}
+
+// string returns the first value of a text attribute
+func (m *ippMessage) string(groupTag byte, name string) string {
+	values := m.strings(groupTag, name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// strings returns every value of a text or keyword attribute
+func (m *ippMessage) strings(groupTag byte, name string) []string {
+	var result []string
+	for _, value := range m.values(groupTag, name) {
+		if s, ok := value.(string); ok && strings.TrimSpace(s) != "" {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,252 @@
+package printer
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// ippStub is an in-process IPP printer. handle answers each decoded request with a status
+// code and the attribute groups that follow the operation group.
+type ippStub struct {
+	t          *testing.T
+	handle     func(req *ippMessage, document []byte) (uint16, []ippGroup)
+	httpStatus int      // Answered instead of an IPP response when set
+	requests   []uint16 // Operations received, in order
+}
+
+func (s *ippStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/ipp" {
+		s.t.Errorf("got %s request with content type %q, want an IPP POST", r.Method, r.Header.Get("Content-Type"))
+	}
+
+	body := bufio.NewReader(r.Body)
+	req, err := decodeIPP(body)
+	if err != nil {
+		s.t.Errorf("invalid IPP request: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	document, _ := io.ReadAll(body)
+	s.requests = append(s.requests, req.code)
+	if s.httpStatus != 0 {
+		w.WriteHeader(s.httpStatus)
+		return
+	}
+
+	if uri := req.string(ippTagOperation, "printer-uri"); uri == "" {
+		s.t.Errorf("request 0x%04x has no printer-uri", req.code)
+	}
+
+	status, groups := s.handle(req, document)
+	resp := &ippMessage{
+		code:      status,
+		requestID: req.requestID,
+		groups: append([]ippGroup{{tag: ippTagOperation, attributes: []ippAttribute{
+			{tag: ippTagCharset, name: "attributes-charset", values: []interface{}{"utf-8"}},
+			{tag: ippTagLanguage, name: "attributes-natural-language", values: []interface{}{"en"}},
+		}}}, groups...),
+	}
+
+	w.Header().Set("Content-Type", "application/ipp")
+	w.Write(resp.encode())
+}
+
+// newIPPStub starts an IPP stub server and returns a transport that talks to it
+func newIPPStub(t *testing.T, handle func(req *ippMessage, document []byte) (uint16, []ippGroup)) (*IPPTransport, *ippStub) {
+	stub := &ippStub{t: t, handle: handle}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	client, err := NewIPPClient(server.URL + "/printers/Office")
+	if err != nil {
+		t.Fatalf("NewIPPClient: %v", err)
+	}
+	return &IPPTransport{Client: client}, stub
+}
+
+func TestIPPPrintJob(t *testing.T) {
+	transport, stub := newIPPStub(t, func(req *ippMessage, document []byte) (uint16, []ippGroup) {
+		if req.code != ippPrintJob {
+			t.Errorf("operation = 0x%04x, want Print-Job", req.code)
+		}
+		if format := req.string(ippTagOperation, "document-format"); format != IPPRawFormat {
+			t.Errorf("document-format = %q, want %s", format, IPPRawFormat)
+		}
+		if string(document) != "receipt" {
+			t.Errorf("document = %q, want the job data", document)
+		}
+		return 0x0000, []ippGroup{{tag: ippTagJob, attributes: []ippAttribute{
+			{tag: ippTagInteger, name: "job-id", values: []interface{}{42}},
+		}}}
+	})
+
+	jobID, err := transport.Submit([]byte("receipt"))
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if jobID != "42" {
+		t.Errorf("job ID = %q, want 42", jobID)
+	}
+	if len(stub.requests) != 1 {
+		t.Errorf("stub received %d requests, want 1", len(stub.requests))
+	}
+}
+
+func TestIPPJobState(t *testing.T) {
+	states := map[int]int{1: 3, 2: 4, 3: 5, 4: 7, 5: 8, 6: 9}
+	transport, _ := newIPPStub(t, func(req *ippMessage, document []byte) (uint16, []ippGroup) {
+		if req.code != ippGetJobAttributes {
+			t.Errorf("operation = 0x%04x, want Get-Job-Attributes", req.code)
+		}
+		jobID, _ := req.int(ippTagOperation, "job-id")
+		state, ok := states[jobID]
+		if !ok {
+			return 0x0406, nil // client-error-not-found
+		}
+		return 0x0000, []ippGroup{{tag: ippTagJob, attributes: []ippAttribute{
+			{tag: ippTagEnum, name: "job-state", values: []interface{}{state}},
+			{tag: ippTagKeyword, name: "job-state-reasons", values: []interface{}{"none"}},
+		}}}
+	})
+
+	for jobID, want := range map[string]SpoolState{
+		"1": SpoolPending,
+		"2": SpoolHeld,
+		"3": SpoolProcessing,
+		"4": SpoolCancelled,
+		"5": SpoolAborted,
+		"6": SpoolCompleted,
+		"7": SpoolUnknown, // Forgotten by the printer
+	} {
+		state, err := transport.JobState(jobID)
+		if err != nil {
+			t.Errorf("JobState(%s): %v", jobID, err)
+			continue
+		}
+		if state != want {
+			t.Errorf("JobState(%s) = %s, want %s", jobID, state, want)
+		}
+	}
+}
+
+func TestIPPCancelJob(t *testing.T) {
+	var cancelled []int
+	transport, _ := newIPPStub(t, func(req *ippMessage, document []byte) (uint16, []ippGroup) {
+		if req.code != ippCancelJob {
+			t.Errorf("operation = 0x%04x, want Cancel-Job", req.code)
+		}
+		if user := req.string(ippTagOperation, "requesting-user-name"); user != "printy" {
+			t.Errorf("requesting-user-name = %q, want printy", user)
+		}
+		jobID, ok := req.int(ippTagOperation, "job-id")
+		if !ok {
+			t.Error("Cancel-Job request has no job-id")
+		}
+		if jobID != 42 {
+			return 0x0406, nil // client-error-not-found
+		}
+		cancelled = append(cancelled, jobID)
+		return 0x0000, nil
+	})
+
+	if err := transport.CancelJob("42"); err != nil {
+		t.Fatalf("CancelJob(42): %v", err)
+	}
+	if len(cancelled) != 1 {
+		t.Errorf("stub cancelled jobs %v, want [42]", cancelled)
+	}
+
+	err := transport.CancelJob("7")
+	var ippErr *IPPError
+	if !errors.As(err, &ippErr) || !ippErr.NotFound() {
+		t.Errorf("CancelJob(7) = %v, want a not found IPP error", err)
+	}
+	if IsTransient(err) {
+		t.Errorf("IsTransient(%v) = true, want false", err)
+	}
+}
+
+func TestIPPPrinterStatus(t *testing.T) {
+	reasons := []interface{}{"none"}
+	state := 3
+	transport, _ := newIPPStub(t, func(req *ippMessage, document []byte) (uint16, []ippGroup) {
+		if req.code != ippGetPrinterAttributes {
+			t.Errorf("operation = 0x%04x, want Get-Printer-Attributes", req.code)
+		}
+		return 0x0000, []ippGroup{{tag: ippTagPrinter, attributes: []ippAttribute{
+			{tag: ippTagEnum, name: "printer-state", values: []interface{}{state}},
+			{tag: ippTagKeyword, name: "printer-state-reasons", values: reasons},
+			{tag: ippTagBoolean, name: "printer-is-accepting-jobs", values: []interface{}{true}},
+		}}}
+	})
+	ip := NewImagePrinter(transport, builtinProfiles[ProfilePOS58])
+
+	if !ip.SupportsStatus() {
+		t.Fatal("IPP printer does not support status")
+	}
+	status, err := ip.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !status.Ready() {
+		t.Errorf("idle printer is not ready: %v", status.Problems())
+	}
+
+	reasons = []interface{}{"media-low-warning", "toner-low-report"}
+	status, err = ip.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !status.Ready() || !status.PaperNearEnd {
+		t.Errorf("status = %+v, want a ready printer near the end of its paper", status)
+	}
+
+	state = 5
+	reasons = []interface{}{"media-empty-error", "cover-open"}
+	status, err = ip.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.Ready() || !status.PaperOut || !status.CoverOpen || status.Online {
+		t.Errorf("status = %+v, want a stopped printer out of paper with its cover open", status)
+	}
+}
+
+func TestIPPErrors(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		http      int    // HTTP status, 200 to answer with the IPP status
+		ipp       uint16 // IPP status code
+		transient bool
+	}{
+		{"not accepting jobs", http.StatusOK, 0x0506, true},
+		{"busy", http.StatusOK, 0x0507, true},
+		{"bad request", http.StatusOK, 0x0400, false},
+		{"unknown queue", http.StatusOK, 0x0406, false},
+		{"http not found", http.StatusNotFound, 0, false},
+		{"http unauthorized", http.StatusUnauthorized, 0, false},
+		{"http upgrade required", http.StatusUpgradeRequired, 0, false},
+		{"http unavailable", http.StatusServiceUnavailable, 0, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			transport, stub := newIPPStub(t, func(req *ippMessage, document []byte) (uint16, []ippGroup) {
+				return test.ipp, nil
+			})
+			if test.http != http.StatusOK {
+				stub.httpStatus = test.http
+			}
+
+			_, err := NewImagePrinter(transport, builtinProfiles[ProfilePOS58]).PrintRaw([]byte("receipt"), nil, nil)
+			if err == nil {
+				t.Fatal("PrintRaw succeeded, want an error")
+			}
+			if IsTransient(err) != test.transient {
+				t.Errorf("IsTransient(%v) = %v, want %v", err, !test.transient, test.transient)
+			}
+		})
+	}
+}
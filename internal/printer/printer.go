@@ -1,19 +1,54 @@
 package printer
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"image"
+	"log"
 	"os"
-	"path/filepath"
+	"strconv"
 )
 
-// Printer handles all printing operations
+// Printer handles all printing operations. It is safe for concurrent use: renders run
+// in parallel on in-memory images and sends to the device are serialized.
 type Printer struct {
-	printerName string
-	outputDir   string
+	printerName   string
+	outputDir     string
+	imagePrinter  *ImagePrinter
+	profile       Profile
+	templates     *Registry
+	rasterizer    Rasterizer
+	keepArtifacts bool          // Keep the SVG, image and ESC/POS data of every job under outputDir/jobs
+	raster        RasterOptions // Defaults for templates and requests that do not set them
 }
 
-// New creates a new printer instance
-func New(printerName string) (*Printer, error) {
+// Rendered is a template converted to an image, ready to be encoded
+type Rendered struct {
+	Image    image.Image
+	Template string        // Name of the template it was rendered from
+	Raster   RasterOptions // Options merged from the defaults, the template and the request
+	QRCodes  []QRCode      // Native QR codes printed below the image
+}
+
+// New creates a new printer instance that renders the shared templates with the given rasterizer
+// and sends jobs over the given transport. Every job is sized and encoded for the printer profile.
+// A nil rasterizer uses the native SVG renderer.
+func New(printerName string, transport Transport, rasterizer Rasterizer, profile Profile, templates *Registry) (*Printer, error) {
+	if transport == nil {
+		return nil, fmt.Errorf("printer %s has no transport", printerName)
+	}
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	if rasterizer == nil {
+		rasterizer = NativeRasterizer{}
+	}
+	if templates == nil {
+		return nil, fmt.Errorf("printer %s has no templates", printerName)
+	}
+
 	// Get output directory relative to executable
 	outputDir, err := GetExecutableRelativePath("tmp/printy")
 	if err != nil {
@@ -24,29 +59,217 @@ func New(printerName string) (*Printer, error) {
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return nil, fmt.Errorf("error creating output directory: %v", err)
 	}
+	keepArtifacts, _ := strconv.ParseBool(os.Getenv("KEEP_ARTIFACTS"))
+
+	raster, err := LoadRasterOptions()
+	if err != nil {
+		return nil, fmt.Errorf("error loading raster options: %v", err)
+	}
 
 	return &Printer{
-		printerName: printerName,
-		outputDir:   outputDir,
+		printerName:   printerName,
+		outputDir:     outputDir,
+		imagePrinter:  NewImagePrinter(transport, profile),
+		profile:       profile,
+		templates:     templates,
+		rasterizer:    rasterizer,
+		keepArtifacts: keepArtifacts,
+		raster:        raster,
 	}, nil
 }
 
-// Print executes the complete printing workflow
-func (p *Printer) Print(ticketID, title, assignee string) error {
-	// File path for PNG (faster conversion with rsvg-convert)
-	pngPath := filepath.Join(p.outputDir, "output.png")
+// Print executes the complete printing workflow with the named template.
+// An empty or unknown template name uses the default template.
+func (p *Printer) Print(templateName, ticketID, title, assignee string, onStage StageFunc) error {
+	ticket := Ticket{RefID: ticketID, Title: title, Assignee: assignee}
+	rendered, err := p.Render(templateName, ticket, RasterOptions{}, nil, onStage)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.PrintRendered(rendered, nil, onStage)
+	return err
+}
+
+// Render fills the named SVG template with a ticket and converts it to an image.
+// Raster options set in the request override the ones of the template.
+// The SVG and image are saved to artifacts when it is not nil.
+func (p *Printer) Render(templateName string, ticket Ticket, raster RasterOptions, artifacts *Artifacts, onStage StageFunc) (*Rendered, error) {
+	svgContent, tmpl, codes, err := p.renderTemplate(TemplateKindTicket, templateName, NewTemplateData(ticket), p.nativeQR(), onStage)
+	if err != nil {
+		return nil, err
+	}
+	return p.rasterize(svgContent, tmpl, codes, raster, artifacts, onStage)
+}
+
+// RenderList fills the named list template with several tickets and converts it to an image,
+// so they print on one receipt. An empty or unknown template name uses the default list template.
+func (p *Printer) RenderList(templateName, assignee string, items []ListItem, raster RasterOptions, artifacts *Artifacts, onStage StageFunc) (*Rendered, error) {
+	svgContent, tmpl, codes, err := p.renderTemplate(TemplateKindList, templateName, NewListData(assignee, items), p.nativeQR(), onStage)
+	if err != nil {
+		return nil, err
+	}
+	return p.rasterize(svgContent, tmpl, codes, raster, artifacts, onStage)
+}
 
-	fmt.Println("🔄 Converting SVG to PNG...")
-	if err := ConvertSVGToImage(pngPath, ticketID, title, assignee); err != nil {
-		return fmt.Errorf("error converting SVG to PNG: %v", err)
+// RenderReport fills the named report template with statistics and converts it to an image.
+// An empty or unknown template name uses the default report template.
+func (p *Printer) RenderReport(templateName string, report Report, raster RasterOptions, artifacts *Artifacts, onStage StageFunc) (*Rendered, error) {
+	svgContent, tmpl, codes, err := p.renderTemplate(TemplateKindReport, templateName, NewReportData(report), p.nativeQR(), onStage)
+	if err != nil {
+		return nil, err
 	}
+	return p.rasterize(svgContent, tmpl, codes, raster, artifacts, onStage)
+}
 
-	imagePrinter := NewImagePrinter(p.printerName)
-	if err := imagePrinter.PrintImage(pngPath, p.printerName); err != nil {
-		return fmt.Errorf("error printing with ESC/POS: %v", err)
+// RenderSVG fills the named SVG template without rasterizing it.
+// QR codes are always drawn in the SVG, even for printers that print them natively.
+func (p *Printer) RenderSVG(templateName string, ticket Ticket) (string, error) {
+	svgContent, _, _, err := p.renderTemplate(TemplateKindTicket, templateName, NewTemplateData(ticket), false, nil)
+	return svgContent, err
+}
+
+// renderTemplate resolves the named template of the given kind, fills it and lays out its
+// QR codes. Native QR codes are returned instead of being drawn.
+func (p *Printer) renderTemplate(kind, templateName string, data TemplateData, nativeQR bool, onStage StageFunc) (string, *Template, []QRCode, error) {
+	var svgContent string
+	var tmpl *Template
+	var codes []QRCode
+	data.NativeQR = nativeQR
+	err := runStage(onStage, StageTemplate, func() error {
+		var err error
+		switch kind {
+		case TemplateKindList:
+			tmpl, err = p.templates.ResolveList(templateName)
+		case TemplateKindReport:
+			tmpl, err = p.templates.ResolveReport(templateName)
+		default:
+			tmpl, err = p.templates.Resolve(templateName)
+		}
+		if err != nil {
+			return err
+		}
+		if svgContent, err = tmpl.Render(data); err != nil {
+			return err
+		}
+
+		dotsPerUnit := float64(p.profile.WidthDots) / float64(tmpl.PaperWidth)
+		svgContent, codes, err = LayoutQRCodes(svgContent, dotsPerUnit, nativeQR)
+		return err
+	})
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to render template: %v", err)
 	}
 
-	return nil
+	return svgContent, tmpl, codes, nil
+}
+
+// nativeQR reports whether QR codes are printed with the QR commands of the printer
+func (p *Printer) nativeQR() bool {
+	return p.profile.Supports(CommandQRCode)
+}
+
+// rasterize converts a filled template to an image with the raster options of the template
+// and the request
+func (p *Printer) rasterize(svgContent string, tmpl *Template, codes []QRCode, raster RasterOptions, artifacts *Artifacts, onStage StageFunc) (*Rendered, error) {
+	artifacts.SaveFile("template.svg", []byte(svgContent))
+
+	var img image.Image
+	err := runStage(onStage, StageRasterize, func() error {
+		var err error
+		img, err = p.rasterizer.Rasterize(svgContent, p.profile.WidthDots)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error converting SVG to image: %v", err)
+	}
+	artifacts.SaveImage("render.png", img)
+
+	return &Rendered{
+		Image:    img,
+		Template: tmpl.Name,
+		Raster:   p.raster.Merge(tmpl.Raster).Merge(raster),
+		QRCodes:  codes,
+	}, nil
+}
+
+// Preview returns the dots a rendered image prints as, with its native QR codes below,
+// without sending anything
+func (p *Printer) Preview(rendered *Rendered) image.Image {
+	return appendQRCodes(DitherImage(rendered.Image, p.profile.WidthDots, rendered.Raster), rendered.QRCodes)
+}
+
+// ImageHash returns the SHA-256 of the dots a rendered image prints as, in hex.
+// Two prints with the same hash look the same on paper.
+func (p *Printer) ImageHash(rendered *Rendered) string {
+	preview := appendQRCodes(DitherImage(rendered.Image, p.profile.WidthDots, rendered.Raster), rendered.QRCodes)
+	bounds := preview.Bounds()
+
+	hash := sha256.New()
+	binary.Write(hash, binary.BigEndian, [2]uint32{uint32(bounds.Dx()), uint32(bounds.Dy())})
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		offset := preview.PixOffset(bounds.Min.X, y)
+		hash.Write(preview.Pix[offset : offset+bounds.Dx()])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Status asks the printer for its real-time status. Printers whose profile or transport
+// cannot report a status return a Status that is not Supported.
+func (p *Printer) Status() (Status, error) {
+	return p.imagePrinter.Status()
+}
+
+// CheckReady returns a *StatusError when the printer reports a problem such as being out
+// of paper. A printer that does not answer is left to fail when the job is sent.
+func (p *Printer) CheckReady(onStage StageFunc) error {
+	if !p.imagePrinter.SupportsStatus() {
+		return nil
+	}
+
+	return runStage(onStage, StageStatus, func() error {
+		status, err := p.Status()
+		if err != nil {
+			log.Printf("⚠️  Warning: Could not read the status of %s: %v", p.printerName, err)
+			return nil
+		}
+		if !status.Ready() {
+			return &StatusError{Status: status}
+		}
+		return nil
+	})
+}
+
+// PrintRendered sends a rendered image to the printer and returns the spooler job ID,
+// empty when the transport prints directly. The ESC/POS data is saved to artifacts when it is not nil.
+func (p *Printer) PrintRendered(rendered *Rendered, artifacts *Artifacts, onStage StageFunc) (string, error) {
+	spoolJobID, err := p.imagePrinter.PrintImage(rendered.Image, rendered.Raster, rendered.QRCodes, artifacts, onStage)
+	if err != nil {
+		return "", fmt.Errorf("error printing with ESC/POS: %w", err)
+	}
+
+	return spoolJobID, nil
+}
+
+// PrintRaw sends an ESC/POS job that is already encoded and returns the spooler job ID,
+// empty when the transport prints directly. The data must be checked with ValidateRaw first.
+func (p *Printer) PrintRaw(data []byte, artifacts *Artifacts, onStage StageFunc) (string, error) {
+	spoolJobID, err := p.imagePrinter.PrintRaw(data, artifacts, onStage)
+	if err != nil {
+		return "", fmt.Errorf("error printing raw job: %w", err)
+	}
+
+	return spoolJobID, nil
+}
+
+// Templates returns the template registry
+func (p *Printer) Templates() *Registry {
+	return p.templates
+}
+
+// Profile returns the printer profile
+func (p *Printer) Profile() Profile {
+	return p.profile
 }
 
 // GetPrinterName returns the printer name
@@ -0,0 +1,155 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Built-in printer profiles
+const (
+	ProfilePOS80 = "pos80" // 80mm paper, 64mm printable at 203 dpi, with cutter
+	ProfilePOS58 = "pos58" // 58mm paper, 48mm printable at 203 dpi, tear bar only
+
+	DefaultProfile = ProfilePOS80
+)
+
+// ESC/POS commands a printer may support
+const (
+	CommandRaster   = "raster"    // GS v 0 raster bit image
+	CommandBitImage = "bit-image" // ESC * column bit image, understood by nearly every printer
+	CommandQRCode   = "qrcode"    // GS ( k native QR codes
+	CommandStatus   = "status"    // DLE EOT real-time status
+)
+
+// Profile describes the paper and capabilities of a printer model
+type Profile struct {
+	Name      string   `json:"name"`
+	WidthDots int      `json:"width_dots"` // Printable width in dots, every render is sized to it
+	DPI       int      `json:"dpi"`
+	Cutter    bool     `json:"cutter"`     // Whether the printer has an automatic cutter
+	FeedLines int      `json:"feed_lines"` // Lines fed after a job so it clears the cutter or tear bar
+	Commands  []string `json:"commands"`   // Supported Command* values
+}
+
+var builtinProfiles = map[string]Profile{
+	ProfilePOS80: {
+		Name:      ProfilePOS80,
+		WidthDots: 512,
+		DPI:       203,
+		Cutter:    true,
+		FeedLines: 1,
+		Commands:  []string{CommandRaster, CommandBitImage, CommandQRCode, CommandStatus},
+	},
+	ProfilePOS58: {
+		Name:      ProfilePOS58,
+		WidthDots: 384,
+		DPI:       203,
+		Cutter:    false,
+		FeedLines: 4,
+		Commands:  []string{CommandRaster, CommandBitImage},
+	},
+}
+
+// Supports reports whether the printer understands an ESC/POS command
+func (p Profile) Supports(command string) bool {
+	for _, c := range p.Commands {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+// WidthMM returns the printable width in millimeters
+func (p Profile) WidthMM() float64 {
+	return float64(p.WidthDots) * 25.4 / float64(p.DPI)
+}
+
+// Validate checks that a profile can be printed with
+func (p Profile) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("profile has no name")
+	}
+	if p.WidthDots <= 0 || p.WidthDots > 0xFFFF {
+		return fmt.Errorf("profile %s: invalid width_dots %d", p.Name, p.WidthDots)
+	}
+	if p.DPI <= 0 {
+		return fmt.Errorf("profile %s: invalid dpi %d", p.Name, p.DPI)
+	}
+	if p.FeedLines < 0 || p.FeedLines > 255 {
+		return fmt.Errorf("profile %s: feed_lines must be between 0 and 255", p.Name)
+	}
+	if !p.Supports(CommandRaster) && !p.Supports(CommandBitImage) {
+		return fmt.Errorf("profile %s: supports neither %s nor %s, images cannot be printed", p.Name, CommandRaster, CommandBitImage)
+	}
+	for _, c := range p.Commands {
+		switch c {
+		case CommandRaster, CommandBitImage, CommandQRCode, CommandStatus:
+		default:
+			return fmt.Errorf("profile %s: unknown command %q", p.Name, c)
+		}
+	}
+	return nil
+}
+
+// LoadProfiles returns the built-in profiles plus the ones defined in the JSON
+// file of PRINTER_PROFILES_FILE, which may replace built-in profiles by name
+func LoadProfiles() (map[string]Profile, error) {
+	profiles := make(map[string]Profile, len(builtinProfiles))
+	for name, profile := range builtinProfiles {
+		profiles[name] = profile
+	}
+
+	path := os.Getenv("PRINTER_PROFILES_FILE")
+	if path == "" {
+		return profiles, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles file: %v", err)
+	}
+
+	var custom []Profile
+	if err := json.Unmarshal(content, &custom); err != nil {
+		return nil, fmt.Errorf("failed to parse profiles file %s: %v", path, err)
+	}
+
+	for _, profile := range custom {
+		profile.Name = strings.ToLower(strings.TrimSpace(profile.Name))
+		if err := profile.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid profile in %s: %v", path, err)
+		}
+		profiles[profile.Name] = profile
+	}
+
+	return profiles, nil
+}
+
+// LoadProfile returns the named profile, or the default profile when the name is empty
+func LoadProfile(name string) (Profile, error) {
+	profiles, err := LoadProfiles()
+	if err != nil {
+		return Profile{}, err
+	}
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	profile, ok := profiles[name]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Profile{}, fmt.Errorf("unknown printer profile %q, available: %s", name, strings.Join(names, ", "))
+	}
+
+	return profile, nil
+}
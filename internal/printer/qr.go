@@ -0,0 +1,245 @@
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/draw"
+	"math"
+	"regexp"
+	"strings"
+
+	"printy/internal/qrcode"
+)
+
+// QR code attributes that can be set on a <rect> placeholder of a template.
+// The placeholder is replaced by a QR code that fills its box, quiet zone included:
+//
+//	data-qr="{{.URL}}"   data to encode, the placeholder is removed when it is empty
+//	data-qr-level="M"    error correction level, L, M, Q or H
+//
+// Printers whose profile supports native QR codes print them with GS ( k below the
+// image instead, so the placeholder is removed from the image. Templates can use
+// .NativeQR to leave out the space the codes would take.
+const (
+	attrQR          = "data-qr"
+	attrQRLevel     = "data-qr-level"
+	defaultQRLevel  = qrcode.LevelM
+	maxQRModuleDots = 16 // Largest module size of GS ( k
+)
+
+var qrPlaceholderPattern = regexp.MustCompile(`<rect\b[^>]*\bdata-qr="[^"]*"[^>]*/>`)
+
+// QRCode is a QR code printed with the native GS ( k commands of the printer
+type QRCode struct {
+	Data       string
+	Level      qrcode.Level
+	ModuleSize int // Width of a module in dots
+}
+
+// LayoutQRCodes replaces every QR placeholder of a filled template. dotsPerUnit is the
+// number of printer dots per SVG user unit, modules are sized to whole dots so they print
+// sharply. In native mode the placeholders are removed and returned as native QR codes.
+func LayoutQRCodes(svgContent string, dotsPerUnit float64, native bool) (string, []QRCode, error) {
+	var layoutErr error
+	var codes []QRCode
+
+	svgContent = qrPlaceholderPattern.ReplaceAllStringFunc(svgContent, func(element string) string {
+		if layoutErr != nil {
+			return element
+		}
+
+		attrs := parseAttrs(element)
+		data := html.UnescapeString(attrs[attrQR])
+		if data == "" {
+			return ""
+		}
+
+		level := defaultQRLevel
+		if name := attrs[attrQRLevel]; name != "" {
+			var err error
+			if level, err = qrcode.ParseLevel(name); err != nil {
+				layoutErr = err
+				return element
+			}
+		}
+
+		code, err := qrcode.Encode([]byte(data), level)
+		if err != nil {
+			layoutErr = fmt.Errorf("failed to encode QR code: %v", err)
+			return element
+		}
+
+		box, err := parseQRBox(attrs)
+		if err != nil {
+			layoutErr = err
+			return element
+		}
+
+		// Whole dots per module, so the code fits the smaller side of the box
+		side := math.Min(box.width, box.height) * dotsPerUnit
+		moduleDots := int(side / float64(code.Size+2*qrcode.QuietZone))
+		if moduleDots < 1 {
+			layoutErr = fmt.Errorf("QR code of %d modules does not fit in a %sx%s box", code.Size, formatFloat(box.width), formatFloat(box.height))
+			return element
+		}
+
+		if native {
+			codes = append(codes, QRCode{
+				Data:       data,
+				Level:      level,
+				ModuleSize: min(moduleDots, maxQRModuleDots),
+			})
+			return ""
+		}
+		return drawQRCode(code, box, moduleDots, dotsPerUnit)
+	})
+	if layoutErr != nil {
+		return "", nil, layoutErr
+	}
+
+	return svgContent, codes, nil
+}
+
+// qrBox is the area of a QR placeholder in SVG user units
+type qrBox struct {
+	x, y, width, height float64
+	transform           string
+}
+
+// parseQRBox reads the position and size of a placeholder
+func parseQRBox(attrs map[string]string) (qrBox, error) {
+	box := qrBox{transform: attrs["transform"]}
+	var err error
+	if box.x, err = floatAttr(attrs, "x", 0); err != nil {
+		return box, err
+	}
+	if box.y, err = floatAttr(attrs, "y", 0); err != nil {
+		return box, err
+	}
+	if box.width, err = floatAttr(attrs, "width", 0); err != nil {
+		return box, err
+	}
+	if box.height, err = floatAttr(attrs, "height", 0); err != nil {
+		return box, err
+	}
+	if box.width <= 0 || box.height <= 0 {
+		return box, fmt.Errorf("QR code placeholder needs a width and a height")
+	}
+	return box, nil
+}
+
+// drawQRCode draws a code centered in its box on a white background, with one path
+// holding the runs of dark modules of every row
+func drawQRCode(code *qrcode.Code, box qrBox, moduleDots int, dotsPerUnit float64) string {
+	module := float64(moduleDots) / dotsPerUnit
+	codeSide := module * float64(code.Size+2*qrcode.QuietZone)
+
+	// Align the code on whole dots
+	snap := func(v float64) float64 {
+		return math.Round(v*dotsPerUnit) / dotsPerUnit
+	}
+	originX := snap(box.x+(box.width-codeSide)/2) + module*qrcode.QuietZone
+	originY := snap(box.y+(box.height-codeSide)/2) + module*qrcode.QuietZone
+
+	var d strings.Builder
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; {
+			if !code.Black(x, y) {
+				x++
+				continue
+			}
+			run := 1
+			for code.Black(x+run, y) {
+				run++
+			}
+			fmt.Fprintf(&d, "M%s %sh%sv%sh-%sz",
+				formatFloat(originX+float64(x)*module), formatFloat(originY+float64(y)*module),
+				formatFloat(float64(run)*module), formatFloat(module), formatFloat(float64(run)*module))
+			x += run
+		}
+	}
+
+	drawn := fmt.Sprintf(`<rect x="%s" y="%s" width="%s" height="%s" fill="white"/><path d="%s" fill="black"/>`,
+		formatFloat(box.x), formatFloat(box.y), formatFloat(box.width), formatFloat(box.height), d.String())
+	if box.transform != "" {
+		// Placeholders moved when the document grows
+		drawn = fmt.Sprintf(`<g transform="%s">%s</g>`, box.transform, drawn)
+	}
+	return drawn
+}
+
+// EncodeQRCode returns the GS ( k commands that store and print a model 2 QR code
+func EncodeQRCode(code QRCode) ([]byte, error) {
+	if code.Level < qrcode.LevelL || code.Level > qrcode.LevelH {
+		return nil, fmt.Errorf("invalid QR code error correction level %d", code.Level)
+	}
+	if code.ModuleSize < 1 || code.ModuleSize > maxQRModuleDots {
+		return nil, fmt.Errorf("QR code module size must be between 1 and %d dots", maxQRModuleDots)
+	}
+	// The store command length includes its 3 header bytes
+	if len(code.Data)+3 > 0xFFFF {
+		return nil, fmt.Errorf("QR code data is too long: %d bytes", len(code.Data))
+	}
+
+	var buf bytes.Buffer
+	qrFunction := func(fn byte, params ...byte) {
+		length := len(params) + 2
+		buf.Write([]byte{0x1D, '(', 'k', byte(length), byte(length >> 8), '1', fn})
+		buf.Write(params)
+	}
+
+	qrFunction('A', '2', 0)                               // Model 2
+	qrFunction('C', byte(code.ModuleSize))                // Module size in dots
+	qrFunction('E', '0'+byte(code.Level))                 // Error correction level, 48 for L to 51 for H
+	qrFunction('P', append([]byte{'0'}, code.Data...)...) // Store the data
+	qrFunction('Q', '0')                                  // Print the stored code
+
+	return buf.Bytes(), nil
+}
+
+// appendQRCodes draws native QR codes centered below an image, as the printer prints them
+func appendQRCodes(img *image.Gray, codes []QRCode) *image.Gray {
+	if len(codes) == 0 {
+		return img
+	}
+
+	type placedCode struct {
+		qr     *qrcode.Code
+		module int
+	}
+	var placed []placedCode
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	for _, code := range codes {
+		qr, err := qrcode.Encode([]byte(code.Data), code.Level)
+		if err != nil {
+			// Codes were encoded once during layout
+			continue
+		}
+		placed = append(placed, placedCode{qr: qr, module: code.ModuleSize})
+		height += (qr.Size + 2*qrcode.QuietZone) * code.ModuleSize
+	}
+
+	out := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(out, out.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(out, img.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	y := img.Bounds().Dy()
+	for _, code := range placed {
+		side := (code.qr.Size + 2*qrcode.QuietZone) * code.module
+		left := (width-side)/2 + qrcode.QuietZone*code.module
+		top := y + qrcode.QuietZone*code.module
+		for my := 0; my < code.qr.Size; my++ {
+			for mx := 0; mx < code.qr.Size; mx++ {
+				if code.qr.Black(mx, my) {
+					module := image.Rect(left+mx*code.module, top+my*code.module, left+(mx+1)*code.module, top+(my+1)*code.module)
+					draw.Draw(out, module, image.Black, image.Point{}, draw.Src)
+				}
+			}
+		}
+		y += side
+	}
+
+	return out
+}
@@ -0,0 +1,300 @@
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Dithering algorithms used to turn grayscale into printer dots
+const (
+	DitherThreshold      = "threshold"       // Every pixel darker than the threshold is printed
+	DitherFloydSteinberg = "floyd-steinberg" // Error diffusion, smooth gradients
+	DitherAtkinson       = "atkinson"        // Error diffusion that keeps more contrast, good for line art
+	DitherBayer          = "bayer"           // Ordered 8x8 pattern, stable output for flat areas
+)
+
+// rasterBandHeight is the number of lines sent in each GS v 0 command.
+// Some printers drop data when a single raster image is too tall for their buffer.
+const rasterBandHeight = 256
+
+// RasterOptions controls how an image is converted to printer dots.
+// Zero values are unset and keep the value they are merged onto.
+type RasterOptions struct {
+	Dither    string  `json:"dither,omitempty"`    // One of the Dither* algorithms
+	Threshold float64 `json:"threshold,omitempty"` // Lightness below which a dot is printed, between 0 and 1
+	Gamma     float64 `json:"gamma,omitempty"`     // Values above 1 darken mid-tones
+	Contrast  float64 `json:"contrast,omitempty"`  // Values above 1 push grays towards black and white
+}
+
+// DefaultRasterOptions prints with a plain 0.5 threshold and no adjustments
+var DefaultRasterOptions = RasterOptions{
+	Dither:    DitherThreshold,
+	Threshold: 0.5,
+	Gamma:     1,
+	Contrast:  1,
+}
+
+// LoadRasterOptions reads the default raster options from RASTER_DITHER,
+// RASTER_THRESHOLD, RASTER_GAMMA and RASTER_CONTRAST
+func LoadRasterOptions() (RasterOptions, error) {
+	opts := RasterOptions{Dither: os.Getenv("RASTER_DITHER")}
+
+	for env, value := range map[string]*float64{
+		"RASTER_THRESHOLD": &opts.Threshold,
+		"RASTER_GAMMA":     &opts.Gamma,
+		"RASTER_CONTRAST":  &opts.Contrast,
+	} {
+		raw := strings.TrimSpace(os.Getenv(env))
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return RasterOptions{}, fmt.Errorf("invalid %s %q", env, raw)
+		}
+		*value = v
+	}
+
+	opts = DefaultRasterOptions.Merge(opts)
+	if err := opts.Validate(); err != nil {
+		return RasterOptions{}, err
+	}
+	return opts, nil
+}
+
+// Merge returns o with every option that is set in override replaced
+func (o RasterOptions) Merge(override RasterOptions) RasterOptions {
+	if override.Dither != "" {
+		o.Dither = strings.ToLower(strings.TrimSpace(override.Dither))
+	}
+	if override.Threshold != 0 {
+		o.Threshold = override.Threshold
+	}
+	if override.Gamma != 0 {
+		o.Gamma = override.Gamma
+	}
+	if override.Contrast != 0 {
+		o.Contrast = override.Contrast
+	}
+	return o
+}
+
+// Validate checks the options that are set
+func (o RasterOptions) Validate() error {
+	switch strings.ToLower(strings.TrimSpace(o.Dither)) {
+	case "", DitherThreshold, DitherFloydSteinberg, DitherAtkinson, DitherBayer:
+	default:
+		return fmt.Errorf("unknown dither %q, expected %s, %s, %s or %s",
+			o.Dither, DitherThreshold, DitherFloydSteinberg, DitherAtkinson, DitherBayer)
+	}
+	if o.Threshold < 0 || o.Threshold > 1 {
+		return fmt.Errorf("threshold must be between 0 and 1, got %v", o.Threshold)
+	}
+	if o.Gamma < 0 {
+		return fmt.Errorf("gamma must be positive, got %v", o.Gamma)
+	}
+	if o.Contrast < 0 {
+		return fmt.Errorf("contrast must be positive, got %v", o.Contrast)
+	}
+	return nil
+}
+
+// EncodeRaster converts an image into GS v 0 raster commands, at most maxWidth dots wide
+func EncodeRaster(img image.Image, maxWidth int, opts RasterOptions) []byte {
+	dots, width, height := ditherImage(img, maxWidth, opts)
+
+	bytesWidth := (width + 7) / 8
+	var buf bytes.Buffer
+	for top := 0; top < height; top += rasterBandHeight {
+		lines := min(rasterBandHeight, height-top)
+
+		// GS v 0 m xL xH yL yH, normal density
+		buf.Write([]byte{0x1D, 'v', '0', 0, byte(bytesWidth), byte(bytesWidth >> 8), byte(lines), byte(lines >> 8)})
+
+		row := make([]byte, bytesWidth)
+		for y := top; y < top+lines; y++ {
+			clear(row)
+			for x := 0; x < width; x++ {
+				if dots[y*width+x] {
+					row[x/8] |= 0x80 >> uint(x%8)
+				}
+			}
+			buf.Write(row)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// EncodeBitImage converts an image into ESC * 24-dot column images, for printers
+// without GS v 0. Each stripe is 24 dots tall and printed with a matching line spacing.
+func EncodeBitImage(img image.Image, maxWidth int, opts RasterOptions) []byte {
+	dots, width, height := ditherImage(img, maxWidth, opts)
+	if width == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	// ESC 3 n, line spacing equal to the stripe height so stripes do not leave gaps
+	buf.Write([]byte{0x1B, '3', 24})
+	for top := 0; top < height; top += 24 {
+		// ESC * m nL nH, 24-dot double density
+		buf.Write([]byte{0x1B, '*', 33, byte(width), byte(width >> 8)})
+
+		column := make([]byte, 3)
+		for x := 0; x < width; x++ {
+			clear(column)
+			for dy := 0; dy < 24 && top+dy < height; dy++ {
+				if dots[(top+dy)*width+x] {
+					column[dy/8] |= 0x80 >> uint(dy%8)
+				}
+			}
+			buf.Write(column)
+		}
+		buf.WriteByte('\n')
+	}
+	// ESC 2, back to the default line spacing
+	buf.Write([]byte{0x1B, '2'})
+
+	return buf.Bytes()
+}
+
+// DitherImage returns the dots the printer prints for an image, black on white, so a preview
+// matches the paper dot for dot
+func DitherImage(img image.Image, maxWidth int, opts RasterOptions) *image.Gray {
+	dots, width, height := ditherImage(img, maxWidth, opts)
+
+	preview := image.NewGray(image.Rect(0, 0, width, height))
+	for i, black := range dots {
+		if !black {
+			preview.Pix[i] = 0xFF
+		}
+	}
+	return preview
+}
+
+// ditherImage converts an image to printer dots, true for black, row by row
+func ditherImage(img image.Image, maxWidth int, opts RasterOptions) (dots []bool, width, height int) {
+	opts = DefaultRasterOptions.Merge(opts)
+
+	bounds := img.Bounds()
+	width, height = bounds.Dx(), bounds.Dy()
+	if maxWidth > 0 && width > maxWidth {
+		// Truncate if the image is too large
+		width = maxWidth
+	}
+	if width <= 0 || height <= 0 {
+		return nil, 0, 0
+	}
+
+	levels := grayLevels(img, width, opts)
+	return ditherLevels(levels, width, height, opts), width, height
+}
+
+// grayLevels returns the lightness of every pixel between 0 (black) and 1 (white),
+// composited over white paper and adjusted by the gamma and contrast options
+func grayLevels(img image.Image, width int, opts RasterOptions) []float64 {
+	bounds := img.Bounds()
+	height := bounds.Dy()
+
+	levels := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+
+			// Colors are premultiplied, so transparent pixels become white paper
+			transparent := float64(0xffff - a)
+			lightness := (0.299*(float64(r)+transparent) +
+				0.587*(float64(g)+transparent) +
+				0.114*(float64(b)+transparent)) / 0xffff
+
+			if opts.Gamma != 1 {
+				lightness = math.Pow(lightness, opts.Gamma)
+			}
+			if opts.Contrast != 1 {
+				lightness = (lightness-0.5)*opts.Contrast + 0.5
+			}
+			levels[y*width+x] = math.Max(0, math.Min(1, lightness))
+		}
+	}
+	return levels
+}
+
+// diffusion is a share of the quantization error passed to a neighbouring pixel
+type diffusion struct {
+	dx, dy int
+	weight float64
+}
+
+var floydSteinbergKernel = []diffusion{
+	{1, 0, 7.0 / 16}, {-1, 1, 3.0 / 16}, {0, 1, 5.0 / 16}, {1, 1, 1.0 / 16},
+}
+
+// Atkinson only spreads 3/4 of the error, which keeps highlights and shadows clean
+var atkinsonKernel = []diffusion{
+	{1, 0, 1.0 / 8}, {2, 0, 1.0 / 8}, {-1, 1, 1.0 / 8}, {0, 1, 1.0 / 8}, {1, 1, 1.0 / 8}, {0, 2, 1.0 / 8},
+}
+
+var bayerMatrix = [8][8]float64{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// ditherLevels decides which pixels are printed as black dots
+func ditherLevels(levels []float64, width, height int, opts RasterOptions) []bool {
+	dots := make([]bool, len(levels))
+
+	switch opts.Dither {
+	case DitherFloydSteinberg:
+		diffuseError(levels, dots, width, height, opts.Threshold, floydSteinbergKernel)
+	case DitherAtkinson:
+		diffuseError(levels, dots, width, height, opts.Threshold, atkinsonKernel)
+	case DitherBayer:
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				dots[y*width+x] = levels[y*width+x] < (bayerMatrix[y%8][x%8]+0.5)/64
+			}
+		}
+	default:
+		for i, level := range levels {
+			dots[i] = level < opts.Threshold
+		}
+	}
+
+	return dots
+}
+
+// diffuseError quantizes every pixel and spreads the error to its neighbours.
+// levels is modified in place.
+func diffuseError(levels []float64, dots []bool, width, height int, threshold float64, kernel []diffusion) {
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			value := 1.0
+			if levels[i] < threshold {
+				dots[i] = true
+				value = 0
+			}
+
+			quantError := levels[i] - value
+			for _, d := range kernel {
+				nx, ny := x+d.dx, y+d.dy
+				if nx < 0 || nx >= width || ny >= height {
+					continue
+				}
+				levels[ny*width+nx] += quantError * d.weight
+			}
+		}
+	}
+}
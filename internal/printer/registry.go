@@ -0,0 +1,307 @@
+package printer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultTemplate is the template used when a ticket does not pick one
+const DefaultTemplate = "sample"
+
+// DefaultListTemplate is the template used for agendas that do not pick one
+const DefaultListTemplate = "agenda"
+
+// DefaultReportTemplate is the template used for reports that do not pick one
+const DefaultReportTemplate = "weekly"
+
+// Template kinds
+const (
+	TemplateKindTicket = "ticket" // Prints one ticket, the default
+	TemplateKindList   = "list"   // Prints the Tickets of TemplateData on one receipt
+	TemplateKindReport = "report" // Prints the Report of TemplateData
+)
+
+// DefaultPaperWidth is the width templates are designed for when they do not declare one
+const DefaultPaperWidth = 512
+
+// Template metadata attributes read from the root <svg> element:
+//
+//	data-printy-name="Urgent ticket"       display name, defaults to the file name
+//	data-printy-kind="list"                ticket, list or report, defaults to ticket
+//	data-printy-paper-width="512"          width the template is designed for, scaled to the printer profile
+//	data-printy-required="Title,Assignee"  fields that must not be empty
+//	data-printy-dither="atkinson"          dithering algorithm, see RasterOptions
+//	data-printy-threshold="0.5"            lightness below which a dot is printed
+//	data-printy-gamma="1.2"                gamma applied before dithering
+//	data-printy-contrast="1.1"             contrast applied before dithering
+const (
+	attrTemplateName       = "data-printy-name"
+	attrTemplateKind       = "data-printy-kind"
+	attrTemplatePaperWidth = "data-printy-paper-width"
+	attrTemplateRequired   = "data-printy-required"
+	attrTemplateDither     = "data-printy-dither"
+	attrTemplateThreshold  = "data-printy-threshold"
+	attrTemplateGamma      = "data-printy-gamma"
+	attrTemplateContrast   = "data-printy-contrast"
+)
+
+// TemplateInfo describes a template loaded in the registry
+type TemplateInfo struct {
+	Name           string        `json:"name"`         // Template key, the file name without .svg
+	DisplayName    string        `json:"display_name"` // Human readable name
+	Kind           string        `json:"kind"`         // TemplateKindTicket, TemplateKindList or TemplateKindReport
+	Path           string        `json:"path"`
+	PaperWidth     int           `json:"paper_width"`     // Design width, renders are scaled to the printer profile
+	RequiredFields []string      `json:"required_fields"` // TemplateData fields that must be set
+	Raster         RasterOptions `json:"raster"`          // Options that override the printer defaults
+}
+
+// Template is a parsed SVG template with its metadata
+type Template struct {
+	TemplateInfo
+	content string
+}
+
+// Registry holds every SVG template of the templates directory
+type Registry struct {
+	dir       string
+	mu        sync.RWMutex
+	templates map[string]*Template
+}
+
+// LoadRegistry loads every .svg file of a directory as a template
+func LoadRegistry(dir string) (*Registry, error) {
+	r := &Registry{dir: dir}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// LoadTemplates loads every SVG template of the templates directory next to the executable
+func LoadTemplates() (*Registry, error) {
+	templatesDir, err := GetExecutableRelativePath("templates")
+	if err != nil {
+		return nil, fmt.Errorf("error getting templates directory: %v", err)
+	}
+
+	templates, err := LoadRegistry(templatesDir)
+	if err != nil {
+		return nil, fmt.Errorf("error loading templates: %v", err)
+	}
+
+	return templates, nil
+}
+
+// Reload reads the templates directory again, replacing the loaded templates
+func (r *Registry) Reload() error {
+	paths, err := filepath.Glob(filepath.Join(r.dir, "*.svg"))
+	if err != nil {
+		return fmt.Errorf("failed to list templates in %s: %v", r.dir, err)
+	}
+
+	templates := make(map[string]*Template)
+	for _, path := range paths {
+		tmpl, err := loadTemplateFile(path)
+		if err != nil {
+			log.Printf("⚠️  Warning: Skipping template %s: %v", path, err)
+			continue
+		}
+		templates[tmpl.Name] = tmpl
+	}
+
+	if len(templates) == 0 {
+		return fmt.Errorf("no SVG templates found in %s", r.dir)
+	}
+
+	r.mu.Lock()
+	r.templates = templates
+	r.mu.Unlock()
+
+	log.Printf("🧩 Loaded %d templates from %s", len(templates), r.dir)
+	return nil
+}
+
+// Get returns a template by name
+func (r *Registry) Get(name string) (*Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tmpl, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("template not found: %s", name)
+	}
+	return tmpl, nil
+}
+
+// Has reports whether a template is loaded
+func (r *Registry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.templates[name]
+	return ok
+}
+
+// Resolve returns the named ticket template, falling back to the default template when
+// the name is empty, unknown or names a list template
+func (r *Registry) Resolve(name string) (*Template, error) {
+	if name != "" {
+		if tmpl, err := r.Get(name); err == nil && tmpl.Kind == TemplateKindTicket {
+			return tmpl, nil
+		}
+		log.Printf("⚠️  Warning: Unknown template %q, using %q", name, DefaultTemplate)
+	}
+
+	return r.Get(DefaultTemplate)
+}
+
+// ResolveList returns the named list template, falling back to the default list template
+// when the name is empty or unknown
+func (r *Registry) ResolveList(name string) (*Template, error) {
+	return r.resolveKind(name, TemplateKindList, DefaultListTemplate)
+}
+
+// ResolveReport returns the named report template, falling back to the default report
+// template when the name is empty or unknown
+func (r *Registry) ResolveReport(name string) (*Template, error) {
+	return r.resolveKind(name, TemplateKindReport, DefaultReportTemplate)
+}
+
+// resolveKind returns the named template of a kind, or the fallback template
+func (r *Registry) resolveKind(name, kind, fallback string) (*Template, error) {
+	if name != "" {
+		if tmpl, err := r.Get(name); err == nil && tmpl.Kind == kind {
+			return tmpl, nil
+		}
+		log.Printf("⚠️  Warning: Unknown %s template %q, using %q", kind, name, fallback)
+	}
+
+	tmpl, err := r.Get(fallback)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl.Kind != kind {
+		return nil, fmt.Errorf("template %s is not a %s template", tmpl.Name, kind)
+	}
+	return tmpl, nil
+}
+
+// List returns the metadata of every template sorted by name
+func (r *Registry) List() []TemplateInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]TemplateInfo, 0, len(r.templates))
+	for _, tmpl := range r.templates {
+		infos = append(infos, tmpl.TemplateInfo)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// Render fills the template with data and lays out its text
+func (t *Template) Render(data TemplateData) (string, error) {
+	for _, field := range t.RequiredFields {
+		value, ok := data.Field(field)
+		if !ok {
+			return "", fmt.Errorf("template %s requires unknown field %s", t.Name, field)
+		}
+		if strings.TrimSpace(value) == "" {
+			return "", fmt.Errorf("template %s requires field %s", t.Name, field)
+		}
+	}
+
+	svgContent, err := RenderTemplate(filepath.Base(t.Path), t.content, data)
+	if err != nil {
+		return "", err
+	}
+
+	// Wrap long text into lines that fit the boxes declared by the template
+	svgContent, err = LayoutText(svgContent)
+	if err != nil {
+		return "", fmt.Errorf("failed to lay out text in %s: %v", t.Path, err)
+	}
+
+	return svgContent, nil
+}
+
+// loadTemplateFile reads and validates a template and its metadata
+func loadTemplateFile(path string) (*Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file: %v", err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if _, err := ParseTemplate(name, string(content)); err != nil {
+		return nil, err
+	}
+
+	root := rootTagPattern.FindString(string(content))
+	if root == "" {
+		return nil, fmt.Errorf("missing <svg> root element")
+	}
+	attrs := parseAttrs(root)
+
+	info := TemplateInfo{
+		Name:        name,
+		DisplayName: attrs[attrTemplateName],
+		Kind:        attrs[attrTemplateKind],
+		Path:        path,
+		PaperWidth:  DefaultPaperWidth,
+	}
+	if info.DisplayName == "" {
+		info.DisplayName = name
+	}
+
+	switch info.Kind {
+	case "":
+		info.Kind = TemplateKindTicket
+	case TemplateKindTicket, TemplateKindList, TemplateKindReport:
+	default:
+		return nil, fmt.Errorf("invalid %s %q", attrTemplateKind, info.Kind)
+	}
+
+	if value := attrs[attrTemplatePaperWidth]; value != "" {
+		width, err := strconv.Atoi(value)
+		if err != nil || width <= 0 {
+			return nil, fmt.Errorf("invalid %s %q", attrTemplatePaperWidth, value)
+		}
+		info.PaperWidth = width
+	}
+
+	info.Raster.Dither = attrs[attrTemplateDither]
+	for attr, value := range map[string]*float64{
+		attrTemplateThreshold: &info.Raster.Threshold,
+		attrTemplateGamma:     &info.Raster.Gamma,
+		attrTemplateContrast:  &info.Raster.Contrast,
+	} {
+		if raw := attrs[attr]; raw != "" {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", attr, raw)
+			}
+			*value = v
+		}
+	}
+	if err := info.Raster.Validate(); err != nil {
+		return nil, err
+	}
+
+	for _, field := range strings.Split(attrs[attrTemplateRequired], ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			info.RequiredFields = append(info.RequiredFields, field)
+		}
+	}
+
+	return &Template{TemplateInfo: info, content: string(content)}, nil
+}
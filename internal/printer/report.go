@@ -0,0 +1,68 @@
+package printer
+
+import "time"
+
+// Report holds the statistics printed by report templates such as the weekly report
+type Report struct {
+	From     time.Time // Start of the first day of the period
+	To       time.Time // End of the period
+	Assignee string    // Assignee the report is limited to, empty for everyone
+	Total    int       // Prints of the period
+
+	Days        []ReportCount // Prints per day, oldest first
+	Assignees   []ReportCount // Prints per assignee, most first
+	MostPrinted []ReportCount // Tickets printed the most during the period
+
+	NeverPrinted      []string // Titles of tickets that were never printed, limited for the receipt
+	NeverPrintedTotal int      // Number of tickets that were never printed
+	Overdue           []ReportOverdue
+	OverdueTotal      int // Number of overdue tickets
+}
+
+// ReportCount is a labeled count, drawn as a bar by report templates
+type ReportCount struct {
+	Label string
+	Count int
+}
+
+// ReportOverdue is a ticket that was not printed again long after its cooldown ended
+type ReportOverdue struct {
+	Title    string
+	Assignee string
+	Late     string // Time since the cooldown ended, such as 3d or 5h
+}
+
+// MaxDay returns the highest count of Days, used to scale the bars
+func (r Report) MaxDay() int {
+	return maxCount(r.Days)
+}
+
+// MaxAssignee returns the highest count of Assignees, used to scale the bars
+func (r Report) MaxAssignee() int {
+	return maxCount(r.Assignees)
+}
+
+// Rows returns the number of lines that vary between reports, so templates can size the document.
+// Empty sections count as one line to say there is nothing to list.
+func (r Report) Rows() int {
+	rows := len(r.Days)
+	for _, n := range []int{len(r.Assignees), len(r.MostPrinted), len(r.NeverPrinted), len(r.Overdue)} {
+		rows += max(n, 1)
+	}
+	if r.NeverPrintedTotal > len(r.NeverPrinted) {
+		rows++
+	}
+	if r.OverdueTotal > len(r.Overdue) {
+		rows++
+	}
+	return rows
+}
+
+// maxCount returns the highest count, at least 1 so it can be divided by
+func maxCount(counts []ReportCount) int {
+	highest := 1
+	for _, c := range counts {
+		highest = max(highest, c.Count)
+	}
+	return highest
+}
@@ -0,0 +1,48 @@
+package printer
+
+import "fmt"
+
+// SpoolState is the state of a job handed to a print spooler such as CUPS
+type SpoolState string
+
+// Spool job states
+const (
+	SpoolPending    SpoolState = "pending"
+	SpoolHeld       SpoolState = "held" // Held by the spooler, for example until an operator releases it
+	SpoolProcessing SpoolState = "processing"
+	SpoolCompleted  SpoolState = "completed"
+	SpoolAborted    SpoolState = "aborted" // Stopped by the spooler because of an error
+	SpoolCancelled  SpoolState = "cancelled"
+	SpoolUnknown    SpoolState = "unknown" // The spooler no longer knows the job
+)
+
+// Final reports whether the spooler is done with the job
+func (s SpoolState) Final() bool {
+	switch s {
+	case SpoolCompleted, SpoolAborted, SpoolCancelled, SpoolUnknown:
+		return true
+	}
+	return false
+}
+
+// SpoolTransport is implemented by transports that hand jobs to a spooler. A job the spooler
+// accepted may still fail later, so its state is followed through the spooler job ID.
+type SpoolTransport interface {
+	Transport
+
+	// Submit sends the job and returns the ID the spooler gave it, empty when the spooler
+	// accepted the job without telling its ID
+	Submit(data []byte) (string, error)
+
+	// JobState returns the current state of a submitted job
+	JobState(jobID string) (SpoolState, error)
+}
+
+// SpoolState asks the spooler of the printer for the state of a job it accepted
+func (p *Printer) SpoolState(jobID string) (SpoolState, error) {
+	spooler, ok := p.imagePrinter.transport.(SpoolTransport)
+	if !ok {
+		return "", fmt.Errorf("printer %s does not print through a spooler", p.printerName)
+	}
+	return spooler.JobState(jobID)
+}
@@ -0,0 +1,29 @@
+package printer
+
+import "time"
+
+// Stage identifies a step of the print pipeline
+type Stage string
+
+// Pipeline stages in the order they run
+const (
+	StageStatus    Stage = "status"    // Checking that the printer is ready
+	StageTemplate  Stage = "template"  // Filling the SVG template
+	StageRasterize Stage = "rasterize" // Converting the SVG to an image
+	StageEncode    Stage = "encode"    // Encoding the image as ESC/POS
+	StageSend      Stage = "send"      // Submitting the job through the transport
+)
+
+// StageFunc is called after each pipeline stage with its duration and error, if any.
+// A nil StageFunc is allowed and ignored.
+type StageFunc func(stage Stage, elapsed time.Duration, err error)
+
+// runStage runs fn and reports it as the given stage
+func runStage(onStage StageFunc, stage Stage, fn func() error) error {
+	startTime := time.Now()
+	err := fn()
+	if onStage != nil {
+		onStage(stage, time.Since(startTime), err)
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package printer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StatusTimeout is how long a printer has to answer a status request
+const StatusTimeout = 2 * time.Second
+
+// StatusTransport is implemented by transports that can read replies back from the printer
+type StatusTransport interface {
+	Transport
+
+	// Exchange writes a request and reads exactly replyLen bytes back
+	Exchange(request []byte, replyLen int, timeout time.Duration) ([]byte, error)
+}
+
+// StatusReporter is implemented by transports that learn the printer state from a spooler
+// or network protocol, such as IPP, instead of DLE EOT replies
+type StatusReporter interface {
+	Transport
+
+	// PrinterStatus returns the current state of the printer
+	PrinterStatus() (Status, error)
+}
+
+// statusRequest asks for the four DLE EOT real-time statuses: printer, offline cause,
+// error cause and paper roll sensor. The printer answers with one byte each.
+var statusRequest = []byte{
+	0x10, 0x04, 1,
+	0x10, 0x04, 2,
+	0x10, 0x04, 3,
+	0x10, 0x04, 4,
+}
+
+// Status is the state reported by a printer
+type Status struct {
+	Supported    bool      `json:"supported"` // False when the printer or its transport cannot report a status
+	Online       bool      `json:"online"`
+	CoverOpen    bool      `json:"cover_open"`
+	PaperOut     bool      `json:"paper_out"`
+	PaperNearEnd bool      `json:"paper_near_end"`
+	CutterError  bool      `json:"cutter_error"`
+	Error        bool      `json:"error"` // Unrecoverable or auto-recoverable error, such as an overheated head
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+// Ready reports whether the printer can print. Printers that cannot report a status are assumed ready.
+func (s Status) Ready() bool {
+	return len(s.Problems()) == 0
+}
+
+// Problems describes every condition that keeps the printer from printing
+func (s Status) Problems() []string {
+	if !s.Supported {
+		return nil
+	}
+
+	var problems []string
+	if s.CoverOpen {
+		problems = append(problems, "cover open")
+	}
+	if s.PaperOut {
+		problems = append(problems, "out of paper")
+	}
+	if s.CutterError {
+		problems = append(problems, "cutter error")
+	}
+	if s.Error {
+		problems = append(problems, "printer error")
+	}
+	if !s.Online && len(problems) == 0 {
+		problems = append(problems, "offline")
+	}
+	return problems
+}
+
+// StatusError reports that the printer is not ready to print, such as when it is out of paper.
+// Jobs are held until the printer recovers instead of being retried.
+type StatusError struct {
+	Status Status
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("printer is not ready: %s", strings.Join(e.Status.Problems(), ", "))
+}
+
+// parseStatus decodes the replies to statusRequest
+func parseStatus(replies []byte) (Status, error) {
+	if len(replies) != 4 {
+		return Status{}, fmt.Errorf("expected 4 status bytes, got %d", len(replies))
+	}
+
+	// Every status byte has bits 1 and 4 set and bits 0 and 7 cleared
+	for i, b := range replies {
+		if b&0x93 != 0x12 {
+			return Status{}, fmt.Errorf("invalid reply 0x%02x to status request %d", b, i+1)
+		}
+	}
+
+	printerStatus, offlineCause, errorCause, paperSensor := replies[0], replies[1], replies[2], replies[3]
+	return Status{
+		Supported:    true,
+		Online:       printerStatus&0x08 == 0,
+		CoverOpen:    offlineCause&0x04 != 0,
+		PaperOut:     offlineCause&0x20 != 0 || paperSensor&0x60 != 0,
+		PaperNearEnd: paperSensor&0x0C != 0,
+		CutterError:  errorCause&0x08 != 0,
+		Error:        errorCause&0x60 != 0,
+		CheckedAt:    time.Now(),
+	}, nil
+}
+
+// ippStatus maps the printer-state-reasons of an IPP printer to a Status. Reasons end with
+// their severity: -report and -warning do not keep the printer from printing, -error or no
+// suffix does.
+func ippStatus(attrs *PrinterAttributes) Status {
+	status := Status{
+		Supported: true,
+		Online:    attrs.State != "stopped" && attrs.AcceptingJobs,
+		CheckedAt: time.Now(),
+	}
+
+	for _, reason := range attrs.StateReasons {
+		keyword, severity := reason, "error"
+		for _, suffix := range []string{"-report", "-warning", "-error"} {
+			if trimmed, ok := strings.CutSuffix(reason, suffix); ok {
+				keyword, severity = trimmed, suffix[1:]
+				break
+			}
+		}
+
+		switch {
+		case keyword == "media-low":
+			status.PaperNearEnd = true
+		case severity != "error":
+		case keyword == "media-empty" || keyword == "media-needed":
+			status.PaperOut = true
+		case keyword == "door-open" || keyword == "cover-open" || keyword == "interlock-open":
+			status.CoverOpen = true
+		case strings.HasPrefix(keyword, "cutter"):
+			status.CutterError = true
+		case keyword == "offline" || keyword == "shutdown" || keyword == "paused":
+			status.Online = false
+		default:
+			status.Error = true
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,555 @@
+package printer
+
+import (
+	"math"
+	"strconv"
+	"unicode"
+)
+
+// point is a 2D coordinate
+type point struct {
+	x, y float64
+}
+
+func (p point) add(q point) point      { return point{p.x + q.x, p.y + q.y} }
+func (p point) sub(q point) point      { return point{p.x - q.x, p.y - q.y} }
+func (p point) mul(k float64) point    { return point{p.x * k, p.y * k} }
+func (p point) length() float64        { return math.Hypot(p.x, p.y) }
+func lerp(p, q point, t float64) point { return p.add(q.sub(p).mul(t)) }
+
+// Path operations; curves keep their control points so they can be transformed exactly
+const (
+	opMove  = 'M'
+	opLine  = 'L'
+	opQuad  = 'Q'
+	opCubic = 'C'
+	opClose = 'Z'
+)
+
+// pathOp is a single path operation with up to three points
+type pathOp struct {
+	kind byte
+	pts  [3]point
+}
+
+// svgPath is a path made of absolute move, line, curve and close operations
+type svgPath []pathOp
+
+// polyline is a flattened subpath
+type polyline struct {
+	pts    []point
+	closed bool
+}
+
+// transform returns the path with every point transformed
+func (p svgPath) transform(m matrix) svgPath {
+	out := make(svgPath, len(p))
+	for i, op := range p {
+		out[i] = op
+		for j := range op.pts {
+			out[i].pts[j] = m.apply(op.pts[j])
+		}
+	}
+	return out
+}
+
+// bounds returns the bounding box of the path points, including control points
+func (p svgPath) bounds() (min, max point, ok bool) {
+	min = point{math.Inf(1), math.Inf(1)}
+	max = point{math.Inf(-1), math.Inf(-1)}
+	for _, op := range p {
+		count := map[byte]int{opMove: 1, opLine: 1, opQuad: 2, opCubic: 3}[op.kind]
+		for _, pt := range op.pts[:count] {
+			min = point{math.Min(min.x, pt.x), math.Min(min.y, pt.y)}
+			max = point{math.Max(max.x, pt.x), math.Max(max.y, pt.y)}
+			ok = true
+		}
+	}
+	return min, max, ok
+}
+
+// flatten converts curves to line segments
+func (p svgPath) flatten() []polyline {
+	var lines []polyline
+	var start, last point
+	current := -1 // Index of the open subpath, -1 when there is none
+
+	ensure := func() {
+		if current < 0 {
+			lines = append(lines, polyline{pts: []point{last}})
+			current = len(lines) - 1
+		}
+	}
+	add := func(pt point) {
+		lines[current].pts = append(lines[current].pts, pt)
+	}
+
+	for _, op := range p {
+		switch op.kind {
+		case opMove:
+			current = -1
+			start, last = op.pts[0], op.pts[0]
+			ensure()
+		case opLine:
+			ensure()
+			add(op.pts[0])
+			last = op.pts[0]
+		case opQuad:
+			ensure()
+			steps := curveSteps(last.sub(op.pts[0]).length() + op.pts[0].sub(op.pts[1]).length())
+			for i := 1; i <= steps; i++ {
+				t := float64(i) / float64(steps)
+				add(lerp(lerp(last, op.pts[0], t), lerp(op.pts[0], op.pts[1], t), t))
+			}
+			last = op.pts[1]
+		case opCubic:
+			ensure()
+			steps := curveSteps(last.sub(op.pts[0]).length() + op.pts[0].sub(op.pts[1]).length() + op.pts[1].sub(op.pts[2]).length())
+			for i := 1; i <= steps; i++ {
+				t := float64(i) / float64(steps)
+				a, b, c := lerp(last, op.pts[0], t), lerp(op.pts[0], op.pts[1], t), lerp(op.pts[1], op.pts[2], t)
+				add(lerp(lerp(a, b, t), lerp(b, c, t), t))
+			}
+			last = op.pts[2]
+		case opClose:
+			if current >= 0 {
+				lines[current].closed = true
+				current = -1
+			}
+			last = start
+		}
+	}
+
+	return lines
+}
+
+// curveSteps returns how many segments a curve of the given control polygon length needs
+func curveSteps(length float64) int {
+	steps := int(math.Ceil(math.Sqrt(length) * 2))
+	if steps < 4 {
+		return 4
+	}
+	if steps > 200 {
+		return 200
+	}
+	return steps
+}
+
+// rectPath returns a rectangle path, with rounded corners when rx or ry are set
+func rectPath(x, y, w, h, rx, ry float64) svgPath {
+	if rx <= 0 && ry <= 0 {
+		return svgPath{
+			{kind: opMove, pts: [3]point{{x, y}}},
+			{kind: opLine, pts: [3]point{{x + w, y}}},
+			{kind: opLine, pts: [3]point{{x + w, y + h}}},
+			{kind: opLine, pts: [3]point{{x, y + h}}},
+			{kind: opClose},
+		}
+	}
+
+	if rx <= 0 {
+		rx = ry
+	}
+	if ry <= 0 {
+		ry = rx
+	}
+	rx = math.Min(rx, w/2)
+	ry = math.Min(ry, h/2)
+
+	// Control point distance for a quarter ellipse
+	const k = 0.5522847498
+	kx, ky := rx*k, ry*k
+	return svgPath{
+		{kind: opMove, pts: [3]point{{x + rx, y}}},
+		{kind: opLine, pts: [3]point{{x + w - rx, y}}},
+		{kind: opCubic, pts: [3]point{{x + w - rx + kx, y}, {x + w, y + ry - ky}, {x + w, y + ry}}},
+		{kind: opLine, pts: [3]point{{x + w, y + h - ry}}},
+		{kind: opCubic, pts: [3]point{{x + w, y + h - ry + ky}, {x + w - rx + kx, y + h}, {x + w - rx, y + h}}},
+		{kind: opLine, pts: [3]point{{x + rx, y + h}}},
+		{kind: opCubic, pts: [3]point{{x + rx - kx, y + h}, {x, y + h - ry + ky}, {x, y + h - ry}}},
+		{kind: opLine, pts: [3]point{{x, y + ry}}},
+		{kind: opCubic, pts: [3]point{{x, y + ry - ky}, {x + rx - kx, y}, {x + rx, y}}},
+		{kind: opClose},
+	}
+}
+
+// ellipsePath returns an ellipse path
+func ellipsePath(cx, cy, rx, ry float64) svgPath {
+	const k = 0.5522847498
+	kx, ky := rx*k, ry*k
+	return svgPath{
+		{kind: opMove, pts: [3]point{{cx + rx, cy}}},
+		{kind: opCubic, pts: [3]point{{cx + rx, cy + ky}, {cx + kx, cy + ry}, {cx, cy + ry}}},
+		{kind: opCubic, pts: [3]point{{cx - kx, cy + ry}, {cx - rx, cy + ky}, {cx - rx, cy}}},
+		{kind: opCubic, pts: [3]point{{cx - rx, cy - ky}, {cx - kx, cy - ry}, {cx, cy - ry}}},
+		{kind: opCubic, pts: [3]point{{cx + kx, cy - ry}, {cx + rx, cy - ky}, {cx + rx, cy}}},
+		{kind: opClose},
+	}
+}
+
+// polyPath returns the path of a polyline or polygon points attribute
+func polyPath(points string, closed bool) svgPath {
+	numbers := parseNumbers(points)
+	var path svgPath
+	for i := 0; i+1 < len(numbers); i += 2 {
+		kind := byte(opLine)
+		if i == 0 {
+			kind = opMove
+		}
+		path = append(path, pathOp{kind: kind, pts: [3]point{{numbers[i], numbers[i+1]}}})
+	}
+	if closed && len(path) > 0 {
+		path = append(path, pathOp{kind: opClose})
+	}
+	return path
+}
+
+// parsePathData parses the d attribute of a path element into absolute operations
+func parsePathData(d string) svgPath {
+	tokens := tokenizePath(d)
+	var path svgPath
+	var current, start, lastControl point
+	var command, lastCommand byte
+
+	i := 0
+	next := func() (float64, bool) {
+		if i >= len(tokens) || tokens[i].command != 0 {
+			return 0, false
+		}
+		i++
+		return tokens[i-1].value, true
+	}
+	nextPoint := func(relative bool) (point, bool) {
+		x, ok1 := next()
+		y, ok2 := next()
+		p := point{x, y}
+		if relative {
+			p = p.add(current)
+		}
+		return p, ok1 && ok2
+	}
+
+	for i < len(tokens) {
+		if tokens[i].command != 0 {
+			command = tokens[i].command
+			i++
+		} else if command == 0 {
+			break
+		}
+
+		relative := unicode.IsLower(rune(command))
+		switch unicode.ToUpper(rune(command)) {
+		case 'M':
+			p, ok := nextPoint(relative)
+			if !ok {
+				return path
+			}
+			path = append(path, pathOp{kind: opMove, pts: [3]point{p}})
+			current, start = p, p
+			// Further coordinate pairs are implicit line commands
+			if relative {
+				command = 'l'
+			} else {
+				command = 'L'
+			}
+		case 'L':
+			p, ok := nextPoint(relative)
+			if !ok {
+				return path
+			}
+			path = append(path, pathOp{kind: opLine, pts: [3]point{p}})
+			current = p
+		case 'H':
+			x, ok := next()
+			if !ok {
+				return path
+			}
+			if relative {
+				x += current.x
+			}
+			current = point{x, current.y}
+			path = append(path, pathOp{kind: opLine, pts: [3]point{current}})
+		case 'V':
+			y, ok := next()
+			if !ok {
+				return path
+			}
+			if relative {
+				y += current.y
+			}
+			current = point{current.x, y}
+			path = append(path, pathOp{kind: opLine, pts: [3]point{current}})
+		case 'C':
+			c1, ok1 := nextPoint(relative)
+			c2, ok2 := nextPoint(relative)
+			p, ok3 := nextPoint(relative)
+			if !ok1 || !ok2 || !ok3 {
+				return path
+			}
+			path = append(path, pathOp{kind: opCubic, pts: [3]point{c1, c2, p}})
+			lastControl, current = c2, p
+		case 'S':
+			c1 := current
+			if lastCommand == 'C' || lastCommand == 'S' {
+				c1 = current.mul(2).sub(lastControl)
+			}
+			c2, ok1 := nextPoint(relative)
+			p, ok2 := nextPoint(relative)
+			if !ok1 || !ok2 {
+				return path
+			}
+			path = append(path, pathOp{kind: opCubic, pts: [3]point{c1, c2, p}})
+			lastControl, current = c2, p
+		case 'Q':
+			c, ok1 := nextPoint(relative)
+			p, ok2 := nextPoint(relative)
+			if !ok1 || !ok2 {
+				return path
+			}
+			path = append(path, pathOp{kind: opQuad, pts: [3]point{c, p}})
+			lastControl, current = c, p
+		case 'T':
+			c := current
+			if lastCommand == 'Q' || lastCommand == 'T' {
+				c = current.mul(2).sub(lastControl)
+			}
+			p, ok := nextPoint(relative)
+			if !ok {
+				return path
+			}
+			path = append(path, pathOp{kind: opQuad, pts: [3]point{c, p}})
+			lastControl, current = c, p
+		case 'A':
+			rx, ok1 := next()
+			ry, ok2 := next()
+			rotation, ok3 := next()
+			largeArc, ok4 := next()
+			sweep, ok5 := next()
+			p, ok6 := nextPoint(relative)
+			if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || !ok6 {
+				return path
+			}
+			path = append(path, arcToCubics(current, p, rx, ry, rotation, largeArc != 0, sweep != 0)...)
+			current = p
+		case 'Z':
+			path = append(path, pathOp{kind: opClose})
+			current = start
+		default:
+			return path
+		}
+		lastCommand = byte(unicode.ToUpper(rune(command)))
+
+		// Close takes no arguments, so numbers after it are invalid
+		if lastCommand == 'Z' {
+			command = 0
+		}
+	}
+
+	return path
+}
+
+// pathToken is either a command letter or a number
+type pathToken struct {
+	command byte
+	value   float64
+}
+
+// tokenizePath splits path data into commands and numbers
+func tokenizePath(d string) []pathToken {
+	var tokens []pathToken
+	for i := 0; i < len(d); {
+		ch := d[i]
+		switch {
+		case ch == ' ' || ch == ',' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case unicode.IsLetter(rune(ch)) && ch != 'e' && ch != 'E':
+			tokens = append(tokens, pathToken{command: ch})
+			i++
+		default:
+			// Numbers can be glued together, as in "1.5.5" or "1-2"
+			j := i
+			if d[j] == '-' || d[j] == '+' {
+				j++
+			}
+			dot := false
+			for j < len(d) && (d[j] >= '0' && d[j] <= '9' || d[j] == '.' && !dot) {
+				if d[j] == '.' {
+					dot = true
+				}
+				j++
+			}
+			if j < len(d) && (d[j] == 'e' || d[j] == 'E') {
+				j++
+				if j < len(d) && (d[j] == '-' || d[j] == '+') {
+					j++
+				}
+				for j < len(d) && d[j] >= '0' && d[j] <= '9' {
+					j++
+				}
+			}
+			if j == i {
+				i++
+				continue
+			}
+			if v, err := strconv.ParseFloat(d[i:j], 64); err == nil {
+				tokens = append(tokens, pathToken{value: v})
+			}
+			i = j
+		}
+	}
+	return tokens
+}
+
+// arcToCubics converts an elliptical arc to cubic curves (SVG implementation notes, F.6)
+func arcToCubics(from, to point, rx, ry, rotation float64, largeArc, sweep bool) svgPath {
+	if from == to {
+		return nil
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		return svgPath{{kind: opLine, pts: [3]point{to}}}
+	}
+
+	sin, cos := math.Sincos(rotation * math.Pi / 180)
+	dx, dy := (from.x-to.x)/2, (from.y-to.y)/2
+	x1 := cos*dx + sin*dy
+	y1 := -sin*dx + cos*dy
+
+	// Scale radii up when they are too small to reach the end point
+	if lambda := x1*x1/(rx*rx) + y1*y1/(ry*ry); lambda > 1 {
+		rx *= math.Sqrt(lambda)
+		ry *= math.Sqrt(lambda)
+	}
+
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := math.Sqrt(math.Max(0, num/den))
+	if largeArc == sweep {
+		coef = -coef
+	}
+	cx1 := coef * rx * y1 / ry
+	cy1 := -coef * ry * x1 / rx
+	cx := cos*cx1 - sin*cy1 + (from.x+to.x)/2
+	cy := sin*cx1 + cos*cy1 + (from.y+to.y)/2
+
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := angle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	delta := angle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	// One cubic per quarter turn at most
+	segments := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	step := delta / float64(segments)
+	k := 4.0 / 3 * math.Tan(step/4)
+
+	ellipse := func(t float64) (point, point) {
+		st, ct := math.Sincos(t)
+		p := point{cx + rx*ct*cos - ry*st*sin, cy + rx*ct*sin + ry*st*cos}
+		d := point{-rx*st*cos - ry*ct*sin, -rx*st*sin + ry*ct*cos}
+		return p, d
+	}
+
+	var path svgPath
+	for i := 0; i < segments; i++ {
+		t1 := theta + float64(i)*step
+		t2 := t1 + step
+		p1, d1 := ellipse(t1)
+		p2, d2 := ellipse(t2)
+		path = append(path, pathOp{kind: opCubic, pts: [3]point{p1.add(d1.mul(k)), p2.sub(d2.mul(k)), p2}})
+	}
+	path[len(path)-1].pts[2] = to
+	return path
+}
+
+// strokePolygons outlines polylines with the given width as polygons that all wind the same way.
+// Joins are round; caps follow linecap (butt, round or square).
+func strokePolygons(lines []polyline, width float64, linecap string) [][]point {
+	half := width / 2
+	var polygons [][]point
+
+	for _, line := range lines {
+		pts := dedupe(line.pts)
+		if line.closed && len(pts) > 1 && pts[0] != pts[len(pts)-1] {
+			pts = append(pts, pts[0])
+		}
+		if len(pts) == 1 {
+			if linecap == "round" {
+				polygons = append(polygons, circlePolygon(pts[0], half))
+			}
+			continue
+		}
+
+		for i := 0; i+1 < len(pts); i++ {
+			a, b := pts[i], pts[i+1]
+			dir := b.sub(a)
+			dir = dir.mul(1 / dir.length())
+
+			// Square caps extend open ends by half the width
+			if !line.closed && linecap == "square" {
+				if i == 0 {
+					a = a.sub(dir.mul(half))
+				}
+				if i+2 == len(pts) {
+					b = b.add(dir.mul(half))
+				}
+			}
+
+			normal := point{-dir.y, dir.x}.mul(half)
+			polygons = append(polygons, orient([]point{a.add(normal), b.add(normal), b.sub(normal), a.sub(normal)}))
+		}
+
+		// Round joins between segments, and round caps at open ends
+		for i, pt := range pts {
+			end := i == 0 || i == len(pts)-1
+			if end && !line.closed && linecap != "round" {
+				continue
+			}
+			polygons = append(polygons, circlePolygon(pt, half))
+		}
+	}
+
+	return polygons
+}
+
+// dedupe removes consecutive duplicate points
+func dedupe(pts []point) []point {
+	out := make([]point, 0, len(pts))
+	for _, pt := range pts {
+		if len(out) == 0 || out[len(out)-1].sub(pt).length() > 1e-9 {
+			out = append(out, pt)
+		}
+	}
+	return out
+}
+
+// circlePolygon approximates a circle with a polygon
+func circlePolygon(center point, radius float64) []point {
+	steps := curveSteps(radius * 2 * math.Pi)
+	pts := make([]point, steps)
+	for i := range pts {
+		sin, cos := math.Sincos(2 * math.Pi * float64(i) / float64(steps))
+		pts[i] = point{center.x + radius*cos, center.y + radius*sin}
+	}
+	return orient(pts)
+}
+
+// orient makes a polygon wind clockwise on screen so overlapping stroke parts add up instead of cancelling
+func orient(pts []point) []point {
+	area := 0.0
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		area += pts[i].x*pts[j].y - pts[j].x*pts[i].y
+	}
+	if area < 0 {
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+	return pts
+}
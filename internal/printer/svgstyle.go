@@ -0,0 +1,292 @@
+package printer
+
+import (
+	"image/color"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// matrix is a 2D affine transform [a c e; b d f]
+type matrix struct {
+	a, b, c, d, e, f float64
+}
+
+// identity is the transform that leaves points unchanged
+var identity = matrix{a: 1, d: 1}
+
+// mul returns the transform that applies n first and then m
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		a: m.a*n.a + m.c*n.b,
+		b: m.b*n.a + m.d*n.b,
+		c: m.a*n.c + m.c*n.d,
+		d: m.b*n.c + m.d*n.d,
+		e: m.a*n.e + m.c*n.f + m.e,
+		f: m.b*n.e + m.d*n.f + m.f,
+	}
+}
+
+// apply transforms a point
+func (m matrix) apply(p point) point {
+	return point{m.a*p.x + m.c*p.y + m.e, m.b*p.x + m.d*p.y + m.f}
+}
+
+// scale returns the average scale factor, used for stroke widths and font sizes
+func (m matrix) scale() float64 {
+	return math.Sqrt(math.Abs(m.a*m.d - m.b*m.c))
+}
+
+// translate returns a translation
+func translate(x, y float64) matrix {
+	return matrix{a: 1, d: 1, e: x, f: y}
+}
+
+var transformPattern = regexp.MustCompile(`(matrix|translate|scale|rotate|skewX|skewY)\s*\(([^)]*)\)`)
+
+// parseTransform parses the value of a transform attribute
+func parseTransform(value string) matrix {
+	m := identity
+	for _, match := range transformPattern.FindAllStringSubmatch(value, -1) {
+		args := parseNumbers(match[2])
+		arg := func(i int, fallback float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return fallback
+		}
+
+		var t matrix
+		switch match[1] {
+		case "matrix":
+			if len(args) != 6 {
+				continue
+			}
+			t = matrix{args[0], args[1], args[2], args[3], args[4], args[5]}
+		case "translate":
+			t = translate(arg(0, 0), arg(1, 0))
+		case "scale":
+			sx := arg(0, 1)
+			t = matrix{a: sx, d: arg(1, sx)}
+		case "rotate":
+			angle := arg(0, 0) * math.Pi / 180
+			cx, cy := arg(1, 0), arg(2, 0)
+			sin, cos := math.Sincos(angle)
+			t = translate(cx, cy).mul(matrix{a: cos, b: sin, c: -sin, d: cos}).mul(translate(-cx, -cy))
+		case "skewX":
+			t = matrix{a: 1, c: math.Tan(arg(0, 0) * math.Pi / 180), d: 1}
+		case "skewY":
+			t = matrix{a: 1, b: math.Tan(arg(0, 0) * math.Pi / 180), d: 1}
+		}
+		m = m.mul(t)
+	}
+	return m
+}
+
+var numberPattern = regexp.MustCompile(`[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`)
+
+// parseNumbers returns every number of a list separated by spaces or commas
+func parseNumbers(value string) []float64 {
+	var numbers []float64
+	for _, field := range numberPattern.FindAllString(value, -1) {
+		if v, err := strconv.ParseFloat(field, 64); err == nil {
+			numbers = append(numbers, v)
+		}
+	}
+	return numbers
+}
+
+// parseLength parses a length in user units; percentages are relative to ref
+func parseLength(value string, ref, fallback float64) float64 {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fallback
+	}
+
+	unit := 1.0
+	switch {
+	case strings.HasSuffix(value, "%"):
+		unit = ref / 100
+		value = strings.TrimSuffix(value, "%")
+	case strings.HasSuffix(value, "px"):
+		value = strings.TrimSuffix(value, "px")
+	case strings.HasSuffix(value, "pt"):
+		unit = 96.0 / 72
+		value = strings.TrimSuffix(value, "pt")
+	case strings.HasSuffix(value, "mm"):
+		unit = 96 / 25.4
+		value = strings.TrimSuffix(value, "mm")
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return fallback
+	}
+	return v * unit
+}
+
+// paint is a fill or stroke value
+type paint struct {
+	none  bool
+	color color.NRGBA
+	ref   string // Element ID for url(#id) paints
+}
+
+var namedColors = map[string]color.NRGBA{
+	"black":       {0, 0, 0, 255},
+	"white":       {255, 255, 255, 255},
+	"red":         {255, 0, 0, 255},
+	"green":       {0, 128, 0, 255},
+	"blue":        {0, 0, 255, 255},
+	"yellow":      {255, 255, 0, 255},
+	"orange":      {255, 165, 0, 255},
+	"gray":        {128, 128, 128, 255},
+	"grey":        {128, 128, 128, 255},
+	"silver":      {192, 192, 192, 255},
+	"lightgray":   {211, 211, 211, 255},
+	"lightgrey":   {211, 211, 211, 255},
+	"darkgray":    {169, 169, 169, 255},
+	"darkgrey":    {169, 169, 169, 255},
+	"transparent": {0, 0, 0, 0},
+}
+
+// parsePaint parses a fill or stroke value; ok is false when the value is invalid
+func parsePaint(value string) (paint, bool) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "none":
+		return paint{none: true}, true
+	case strings.HasPrefix(value, "url("):
+		ref := strings.TrimSuffix(strings.TrimPrefix(value, "url("), ")")
+		ref = strings.Trim(strings.TrimSpace(ref), `"'`)
+		return paint{ref: strings.TrimPrefix(ref, "#")}, true
+	}
+
+	c, ok := parseColor(value)
+	return paint{color: c}, ok
+}
+
+// parseColor parses named, #rgb, #rrggbb and rgb()/rgba() colors
+func parseColor(value string) (color.NRGBA, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if c, ok := namedColors[value]; ok {
+		return c, true
+	}
+
+	if strings.HasPrefix(value, "#") {
+		hex := value[1:]
+		if len(hex) == 3 || len(hex) == 4 {
+			expanded := ""
+			for _, ch := range hex {
+				expanded += string(ch) + string(ch)
+			}
+			hex = expanded
+		}
+		if len(hex) != 6 && len(hex) != 8 {
+			return color.NRGBA{}, false
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return color.NRGBA{}, false
+		}
+		if len(hex) == 6 {
+			return color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, true
+		}
+		return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, true
+	}
+
+	if strings.HasPrefix(value, "rgb") {
+		args := parseNumbers(value)
+		if len(args) < 3 {
+			return color.NRGBA{}, false
+		}
+		alpha := 1.0
+		if len(args) > 3 {
+			alpha = args[3]
+		}
+		return color.NRGBA{clampByte(args[0]), clampByte(args[1]), clampByte(args[2]), clampByte(alpha * 255)}, true
+	}
+
+	return color.NRGBA{}, false
+}
+
+// clampByte rounds and clamps a value to 0-255
+func clampByte(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
+
+// svgStyle holds the computed presentation properties of an element
+type svgStyle struct {
+	fill          paint
+	stroke        paint
+	strokeWidth   float64
+	strokeLinecap string
+	fillOpacity   float64
+	strokeOpacity float64
+	fontFamily    string
+	fontSize      float64
+	fontWeight    string
+	textAnchor    string
+	hidden        bool
+}
+
+// defaultStyle is the initial style of the root element
+var defaultStyle = svgStyle{
+	fill:          paint{color: color.NRGBA{0, 0, 0, 255}},
+	stroke:        paint{none: true},
+	strokeWidth:   1,
+	strokeLinecap: "butt",
+	fillOpacity:   1,
+	strokeOpacity: 1,
+	fontSize:      defaultFontSize,
+	textAnchor:    "start",
+}
+
+// inherit returns the style of a child element from its parent style and own properties
+func (s svgStyle) inherit(n *svgNode) svgStyle {
+	if v := n.prop("fill"); v != "" && v != "inherit" {
+		if p, ok := parsePaint(v); ok {
+			s.fill = p
+		}
+	}
+	if v := n.prop("stroke"); v != "" && v != "inherit" {
+		if p, ok := parsePaint(v); ok {
+			s.stroke = p
+		}
+	}
+	if v := n.prop("stroke-width"); v != "" {
+		s.strokeWidth = parseLength(v, 0, s.strokeWidth)
+	}
+	if v := n.prop("stroke-linecap"); v != "" {
+		s.strokeLinecap = v
+	}
+	if v := n.prop("fill-opacity"); v != "" {
+		s.fillOpacity = parseLength(v, 1, s.fillOpacity)
+	}
+	if v := n.prop("stroke-opacity"); v != "" {
+		s.strokeOpacity = parseLength(v, 1, s.strokeOpacity)
+	}
+	if v := n.prop("font-family"); v != "" {
+		s.fontFamily = v
+	}
+	if v := n.prop("font-size"); v != "" {
+		s.fontSize = parseLength(v, s.fontSize, s.fontSize)
+	}
+	if v := n.prop("font-weight"); v != "" {
+		s.fontWeight = v
+	}
+	if v := n.prop("text-anchor"); v != "" {
+		s.textAnchor = v
+	}
+	if v := n.prop("visibility"); v != "" {
+		s.hidden = v == "hidden" || v == "collapse"
+	}
+	return s
+}
+
+// withOpacity returns a paint color with an opacity applied
+func withOpacity(c color.NRGBA, opacity float64) color.NRGBA {
+	c.A = clampByte(float64(c.A) * opacity)
+	return c
+}
@@ -1,76 +1,65 @@
 package printer
 
 import (
+	"bytes"
 	"fmt"
-	"os"
+	"image"
 	"os/exec"
-	"path/filepath"
+	"strconv"
 	"strings"
-	"time"
 )
 
-// ConvertSVGToImage converts SVG template to JPEG image using rsvg-convert
-func ConvertSVGToImage(outputPath string, ticketID, title, assignee string) error {
-	// Check if rsvg-convert is available
-	if _, err := exec.LookPath("rsvg-convert"); err != nil {
-		return fmt.Errorf("rsvg-convert not found. Please install it with: sudo apt-get install librsvg2-bin")
-	}
+// SVG renderers that can be selected with SVG_RENDERER
+const (
+	RendererNative = "native" // In-process renderer, no external dependencies
+	RendererRSVG   = "rsvg"   // rsvg-convert from librsvg2-bin
+)
 
-	// Load SVG content from templates folder
-	svgContent, err := loadSVGFromTemplates(ticketID, title, assignee)
-	if err != nil {
-		return fmt.Errorf("failed to load SVG from templates: %v", err)
-	}
+// Rasterizer converts SVG content to an image of the given width in dots
+type Rasterizer interface {
+	Rasterize(svgContent string, width int) (image.Image, error)
+}
 
-	// Ensure output directory exists
-	outputDir := filepath.Dir(outputPath)
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %v", err)
+// NewRasterizer creates the SVG renderer with the given name, defaulting to the native one
+func NewRasterizer(name string) (Rasterizer, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", RendererNative:
+		return NativeRasterizer{}, nil
+	case RendererRSVG:
+		// Check if rsvg-convert is available
+		if _, err := exec.LookPath("rsvg-convert"); err != nil {
+			return nil, fmt.Errorf("rsvg-convert not found. Please install it with: sudo apt-get install librsvg2-bin")
+		}
+		return RSVGRasterizer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown SVG renderer %q", name)
 	}
+}
 
-	// Create temporary SVG file
-	tempSVGPath := filepath.Join(outputDir, "temp.svg")
-	if err := os.WriteFile(tempSVGPath, []byte(svgContent), 0644); err != nil {
-		return fmt.Errorf("failed to write temporary SVG file: %v", err)
-	}
-	defer os.Remove(tempSVGPath) // Clean up temp file
+// RSVGRasterizer converts SVG content using the rsvg-convert command
+type RSVGRasterizer struct{}
 
-	// Convert SVG to PNG using rsvg-convert (much faster than ImageMagick)
-	// Force width to 384 dots (standard thermal printer width) and maintain aspect ratio
+// Rasterize pipes the SVG through rsvg-convert and decodes the PNG it writes
+func (RSVGRasterizer) Rasterize(svgContent string, width int) (image.Image, error) {
+	// Force width to the paper width and maintain aspect ratio
 	cmd := exec.Command("rsvg-convert",
-		"--width", "512", // Force width to 384 dots, height auto-calculated
+		"--width", strconv.Itoa(width), // Height auto-calculated
 		"--format", "png", // Output PNG format directly
-		"--output", outputPath,
-		tempSVGPath,
 	)
+	cmd.Stdin = strings.NewReader(svgContent)
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("rsvg-convert SVG to PNG conversion failed: %v", err)
-	}
-
-	return nil
-}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
 
-// loadSVGFromTemplates loads SVG content from the templates folder
-func loadSVGFromTemplates(ticketID, title, assignee string) (string, error) {
-	// Get template path relative to executable directory
-	templatePath, err := GetExecutableRelativePath("templates/sample.svg")
-	if err != nil {
-		return "", fmt.Errorf("failed to get template path: %v", err)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("rsvg-convert SVG to PNG conversion failed: %v: %s", err, strings.TrimSpace(stderr.String()))
 	}
 
-	content, err := os.ReadFile(templatePath)
+	img, _, err := image.Decode(&stdout)
 	if err != nil {
-		return "", fmt.Errorf("failed to read template file %s: %v", templatePath, err)
+		return nil, fmt.Errorf("failed to decode rsvg-convert output: %v", err)
 	}
 
-	// Replace placeholders
-	svgContent := string(content)
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	svgContent = strings.ReplaceAll(svgContent, "{{.Timestamp}}", timestamp)
-	svgContent = strings.ReplaceAll(svgContent, "{{.TicketID}}", ticketID)
-	svgContent = strings.ReplaceAll(svgContent, "{{.Title}}", title)
-	svgContent = strings.ReplaceAll(svgContent, "{{.Assignee}}", assignee)
-
-	return svgContent, nil
+	return img, nil
 }
@@ -0,0 +1,318 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultTimezone is used for template dates when TIMEZONE is not set
+const DefaultTimezone = "America/Montreal"
+
+// TemplateData holds the fields available to SVG templates
+type TemplateData struct {
+	Timestamp   string    // Print time formatted as 2006-01-02 15:04:05
+	Now         time.Time // Print time, for use with the date helper
+	TicketID    string
+	Title       string
+	Assignee    string
+	URL         string     // Notion page of the ticket, for data-qr placeholders
+	CompleteURL string     // Marks the ticket as done when opened, empty when PUBLIC_URL is not set
+	PrintCode   string     // Identifies this print to the barcode scanner, such as PRINT-42, empty in previews
+	NativeQR    bool       // QR codes print natively below the image instead of where their placeholder is
+	Tickets     []ListItem // Tickets of list templates such as the agenda, in print order
+	Report      *Report    // Statistics of report templates, nil for other templates
+}
+
+// Ticket holds the ticket fields a ticket template is filled with
+type Ticket struct {
+	RefID       string
+	Title       string
+	Assignee    string
+	URL         string // Notion page URL
+	CompleteURL string // Completion URL, empty when completions cannot be reached
+	PrintCode   string // Code of the print record, empty when the print is not recorded
+}
+
+// ListItem is a ticket printed as one line of a list template
+type ListItem struct {
+	TicketID string
+	Title    string
+	Assignee string
+	Priority int    // 3 for Alta, 2 for Media, 1 for Baja
+	Marker   string // Priority as exclamation marks, such as !!! for Alta
+}
+
+// NewListItem creates a list line with the marker of its priority
+func NewListItem(ticketID, title, assignee string, priority int) ListItem {
+	return ListItem{
+		TicketID: ticketID,
+		Title:    title,
+		Assignee: assignee,
+		Priority: priority,
+		Marker:   strings.Repeat("!", max(priority, 0)),
+	}
+}
+
+// SafeXML is markup produced by a helper that must be inserted without escaping
+type SafeXML string
+
+// escapeFunc is the name of the function appended to every template action
+const escapeFunc = "xmlEscape"
+
+// NewTemplateData creates template data for a ticket stamped with the current time
+func NewTemplateData(ticket Ticket) TemplateData {
+	now := time.Now().In(TemplateLocation())
+	return TemplateData{
+		Timestamp:   now.Format("2006-01-02 15:04:05"),
+		Now:         now,
+		TicketID:    ticket.RefID,
+		Title:       ticket.Title,
+		Assignee:    ticket.Assignee,
+		URL:         ticket.URL,
+		CompleteURL: ticket.CompleteURL,
+		PrintCode:   ticket.PrintCode,
+	}
+}
+
+// NewListData creates template data for a list of tickets stamped with the current time
+func NewListData(assignee string, items []ListItem) TemplateData {
+	data := NewTemplateData(Ticket{Assignee: assignee})
+	data.Tickets = items
+	return data
+}
+
+// NewReportData creates template data for a report stamped with the current time
+func NewReportData(report Report) TemplateData {
+	data := NewTemplateData(Ticket{Assignee: report.Assignee})
+	data.Report = &report
+	return data
+}
+
+// CountPriority returns how many tickets of a list have the given priority
+func (d TemplateData) CountPriority(priority int) int {
+	count := 0
+	for _, item := range d.Tickets {
+		if item.Priority == priority {
+			count++
+		}
+	}
+	return count
+}
+
+// Field returns a text field by name, as used in template metadata
+func (d TemplateData) Field(name string) (string, bool) {
+	switch name {
+	case "TicketID":
+		return d.TicketID, true
+	case "Title":
+		return d.Title, true
+	case "Assignee":
+		return d.Assignee, true
+	case "Timestamp":
+		return d.Timestamp, true
+	case "URL":
+		return d.URL, true
+	case "CompleteURL":
+		return d.CompleteURL, true
+	case "PrintCode":
+		return d.PrintCode, true
+	}
+	return "", false
+}
+
+// RenderTemplate executes an SVG template, escaping every value so the output stays valid XML
+func RenderTemplate(name, content string, data interface{}) (string, error) {
+	tmpl, err := ParseTemplate(name, content)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template %s: %v", name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// ParseTemplate parses an SVG template and makes every action XML-escaped
+func ParseTemplate(name, content string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs()).Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %v", name, err)
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			escapeNode(t.Tree, t.Tree.Root)
+		}
+	}
+
+	return tmpl, nil
+}
+
+// templateFuncs returns the helper functions available to templates.
+// Helpers take the piped value last so they can be used as {{.Title | truncate 20}}.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		escapeFunc: escapeXML,
+		"upper":    strings.ToUpper,
+		"lower":    strings.ToLower,
+		"truncate": truncate,
+		"wrap":     wrapText,
+		"date":     formatDate,
+		"add":      add,
+		"mul":      mul,
+		"sub":      sub,
+		"scale":    scale,
+	}
+}
+
+// escapeNode appends the escape function to every action that writes output
+func escapeNode(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			escapeNode(tree, child)
+		}
+	case *parse.ActionNode:
+		// Variable declarations do not write anything
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		identifier := parse.NewIdentifier(escapeFunc).SetTree(tree).SetPos(n.Pos)
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{identifier},
+		})
+	case *parse.IfNode:
+		escapeNode(tree, n.List)
+		escapeNode(tree, n.ElseList)
+	case *parse.RangeNode:
+		escapeNode(tree, n.List)
+		escapeNode(tree, n.ElseList)
+	case *parse.WithNode:
+		escapeNode(tree, n.List)
+		escapeNode(tree, n.ElseList)
+	}
+}
+
+// escapeXML converts a template value to text that is safe inside XML content and attributes
+func escapeXML(value interface{}) string {
+	switch v := value.(type) {
+	case SafeXML:
+		return string(v)
+	case nil:
+		return ""
+	}
+
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(fmt.Sprint(value)))
+	return buf.String()
+}
+
+// truncate shortens text to at most n characters, ending with an ellipsis when cut
+func truncate(n int, text string) string {
+	if n <= 0 || utf8.RuneCountInString(text) <= n {
+		return text
+	}
+
+	runes := []rune(text)
+	if n == 1 {
+		return string(runes[:1])
+	}
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
+// wrapText splits text into lines of at most width characters, breaking between words.
+// Words longer than the width are split.
+func wrapText(width int, text string) []string {
+	words := strings.Fields(text)
+	if width <= 0 || len(words) == 0 {
+		return []string{text}
+	}
+
+	var lines []string
+	current := ""
+	for _, word := range words {
+		for utf8.RuneCountInString(word) > width {
+			if current != "" {
+				lines = append(lines, current)
+				current = ""
+			}
+			runes := []rune(word)
+			lines = append(lines, string(runes[:width]))
+			word = string(runes[width:])
+		}
+
+		switch {
+		case current == "":
+			current = word
+		case utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) <= width:
+			current += " " + word
+		default:
+			lines = append(lines, current)
+			current = word
+		}
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+
+	return lines
+}
+
+// add returns a + b, used to position repeated elements such as list lines
+func add(a, b int) int {
+	return a + b
+}
+
+// mul returns a * b, used to position repeated elements such as list lines
+func mul(a, b int) int {
+	return a * b
+}
+
+// sub returns a - b
+func sub(a, b int) int {
+	return a - b
+}
+
+// scale returns the length of a bar for value when highest fills width, used to draw charts
+func scale(width, highest, value int) int {
+	if highest <= 0 || value <= 0 {
+		return 0
+	}
+	return min(value*width/highest, width)
+}
+
+// formatDate formats a time in the configured time zone
+func formatDate(layout string, t time.Time) string {
+	return t.In(TemplateLocation()).Format(layout)
+}
+
+// TemplateLocation returns the time zone from TIMEZONE, defaulting to Montreal
+func TemplateLocation() *time.Location {
+	name := os.Getenv("TIMEZONE")
+	if name == "" {
+		name = DefaultTimezone
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("⚠️  Warning: Unknown time zone %q, using local time: %v", name, err)
+		return time.Local
+	}
+	return location
+}
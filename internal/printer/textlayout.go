@@ -0,0 +1,434 @@
+package printer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+)
+
+// Text layout attributes that can be set on a <text> element of a template.
+// A text element with data-box is wrapped into <tspan> lines that fit the box:
+//
+//	data-box="x y width height"  box the text must fit in, in SVG user units
+//	data-valign="top"            top, middle or bottom placement inside the box
+//	data-line-height="1.2"       line height as a multiple of the font size
+//	data-max-lines="0"           maximum number of lines, 0 for no limit
+//	data-fit="grow"              grow the document or shrink the font when the text does not fit
+//	data-max-grow="400"          maximum extra height when growing
+//	data-min-font-size="12"      smallest font size when shrinking
+//
+// When the document grows, elements with data-on-grow="stretch" get taller and
+// elements with data-on-grow="move" move down by the same amount.
+const (
+	attrBox           = "data-box"
+	attrVAlign        = "data-valign"
+	attrLineHeight    = "data-line-height"
+	attrMaxLines      = "data-max-lines"
+	attrFit           = "data-fit"
+	attrMaxGrow       = "data-max-grow"
+	attrMinFontSize   = "data-min-font-size"
+	attrOnGrow        = "data-on-grow"
+	defaultLineHeight = 1.2
+	defaultMaxGrow    = 400
+	defaultMinFont    = 12
+	defaultFontSize   = 16
+	ellipsis          = "…"
+)
+
+var (
+	textElementPattern = regexp.MustCompile(`(?s)<text\b[^>]*\bdata-box="[^"]*"[^>]*>.*?</text>`)
+	startTagPattern    = regexp.MustCompile(`^<[^>]*>`)
+	attrPattern        = regexp.MustCompile(`([\w:-]+)\s*=\s*"([^"]*)"`)
+	rootTagPattern     = regexp.MustCompile(`<svg\b[^>]*>`)
+	growTagPattern     = regexp.MustCompile(`<\w+\b[^>]*\bdata-on-grow="[^"]*"[^>]*>`)
+)
+
+// textBox is the layout request declared on a text element
+type textBox struct {
+	x, y, width, height float64
+	valign              string
+	lineHeight          float64
+	maxLines            int
+	fit                 string
+	maxGrow             float64
+	minFontSize         float64
+	fontSize            float64
+	fontFamily          string
+	bold                bool
+	anchor              string
+}
+
+// LayoutText wraps every text element with a data-box into lines that fit the box,
+// growing the document or shrinking the font as requested by the template
+func LayoutText(svgContent string) (string, error) {
+	var layoutErr error
+	grow := 0.0
+	fonts := embeddedFonts(svgContent)
+
+	svgContent = textElementPattern.ReplaceAllStringFunc(svgContent, func(element string) string {
+		if layoutErr != nil {
+			return element
+		}
+
+		laidOut, delta, err := layoutTextElement(element, fonts)
+		if err != nil {
+			layoutErr = err
+			return element
+		}
+		grow += delta
+		return laidOut
+	})
+	if layoutErr != nil {
+		return "", layoutErr
+	}
+
+	if grow > 0 {
+		svgContent = growDocument(svgContent, grow)
+	}
+
+	return svgContent, nil
+}
+
+// layoutTextElement lays out a single text element and returns how much the document must grow
+func layoutTextElement(element string, fonts fontSet) (string, float64, error) {
+	startTag := startTagPattern.FindString(element)
+	attrs := parseAttrs(startTag)
+
+	box, err := parseTextBox(attrs)
+	if err != nil {
+		return "", 0, err
+	}
+
+	text, err := innerText(element)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid text element: %v", err)
+	}
+
+	f := fonts.font(box.fontFamily, box.bold)
+	size := box.fontSize
+	var lines []string
+	var face font.Face
+	for {
+		face = newFace(f, size)
+		lines = wrapToWidth(face, text, box.width)
+		if box.fit != "shrink" || size <= box.minFontSize || fitsBox(box, lines, size) {
+			break
+		}
+		size--
+	}
+
+	// Limit the number of lines to what the box, the growth limit and max-lines allow
+	lineHeight := size * box.lineHeight
+	available := box.height
+	if box.fit != "shrink" {
+		available += box.maxGrow
+	}
+	maxLines := int(available / lineHeight)
+	if maxLines < 1 {
+		maxLines = 1
+	}
+	if box.maxLines > 0 && box.maxLines < maxLines {
+		maxLines = box.maxLines
+	}
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		lines[maxLines-1] = ellipsize(face, lines[maxLines-1], box.width)
+	}
+
+	blockHeight := float64(len(lines)) * lineHeight
+	grow := 0.0
+	if box.fit != "shrink" && blockHeight > box.height {
+		grow = blockHeight - box.height
+	}
+
+	// Place the block inside the (possibly grown) box
+	top := box.y
+	switch box.valign {
+	case "middle":
+		top = box.y + (box.height+grow-blockHeight)/2
+	case "bottom":
+		top = box.y + box.height + grow - blockHeight
+	}
+
+	metrics := face.Metrics()
+	ascent := fixedToFloat(metrics.Ascent)
+	descent := fixedToFloat(metrics.Descent)
+	firstBaseline := top + (lineHeight-(ascent+descent))/2 + ascent
+
+	x := box.x
+	switch box.anchor {
+	case "middle":
+		x = box.x + box.width/2
+	case "end":
+		x = box.x + box.width
+	}
+
+	var b strings.Builder
+	b.WriteString(rebuildStartTag(startTag, size))
+	for i, line := range lines {
+		fmt.Fprintf(&b, `<tspan x="%s" y="%s">`, formatFloat(x), formatFloat(firstBaseline+float64(i)*lineHeight))
+		xml.EscapeText(&b, []byte(line))
+		b.WriteString("</tspan>")
+	}
+	b.WriteString("</text>")
+
+	return b.String(), grow, nil
+}
+
+// parseTextBox reads the layout attributes of a text element
+func parseTextBox(attrs map[string]string) (textBox, error) {
+	box := textBox{
+		valign:      attrs[attrVAlign],
+		lineHeight:  defaultLineHeight,
+		fit:         strings.ToLower(attrs[attrFit]),
+		maxGrow:     defaultMaxGrow,
+		minFontSize: defaultMinFont,
+		fontSize:    defaultFontSize,
+		fontFamily:  attrs["font-family"],
+		anchor:      attrs["text-anchor"],
+	}
+
+	fields := strings.Fields(strings.ReplaceAll(attrs[attrBox], ",", " "))
+	if len(fields) != 4 {
+		return box, fmt.Errorf("%s must be \"x y width height\", got %q", attrBox, attrs[attrBox])
+	}
+	values := make([]float64, 4)
+	for i, field := range fields {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return box, fmt.Errorf("invalid %s value %q: %v", attrBox, field, err)
+		}
+		values[i] = v
+	}
+	box.x, box.y, box.width, box.height = values[0], values[1], values[2], values[3]
+
+	// Style properties override presentation attributes
+	style := parseStyle(attrs["style"])
+	for name, value := range style {
+		switch name {
+		case "font-size", "font-family", "font-weight", "text-anchor":
+			attrs[name] = value
+		}
+	}
+	box.fontFamily = attrs["font-family"]
+	box.anchor = attrs["text-anchor"]
+	box.bold = isBold(attrs["font-weight"])
+
+	var err error
+	if box.fontSize, err = floatAttr(attrs, "font-size", defaultFontSize); err != nil {
+		return box, err
+	}
+	if box.lineHeight, err = floatAttr(attrs, attrLineHeight, defaultLineHeight); err != nil {
+		return box, err
+	}
+	if box.maxGrow, err = floatAttr(attrs, attrMaxGrow, defaultMaxGrow); err != nil {
+		return box, err
+	}
+	if box.minFontSize, err = floatAttr(attrs, attrMinFontSize, defaultMinFont); err != nil {
+		return box, err
+	}
+	maxLines, err := floatAttr(attrs, attrMaxLines, 0)
+	if err != nil {
+		return box, err
+	}
+	box.maxLines = int(maxLines)
+
+	return box, nil
+}
+
+// fitsBox reports whether the lines fit the box height at the given font size
+func fitsBox(box textBox, lines []string, size float64) bool {
+	if box.maxLines > 0 && len(lines) > box.maxLines {
+		return false
+	}
+	return float64(len(lines))*size*box.lineHeight <= box.height
+}
+
+// wrapToWidth breaks text into lines no wider than width, splitting words that are too long
+func wrapToWidth(face font.Face, text string, width float64) []string {
+	var lines []string
+	current := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+		if measureText(face, candidate) <= width {
+			current = candidate
+			continue
+		}
+
+		if current != "" {
+			lines = append(lines, current)
+		}
+		current = word
+
+		// Break words that do not fit on a line of their own
+		for measureText(face, current) > width {
+			runes := []rune(current)
+			cut := len(runes) - 1
+			for cut > 1 && measureText(face, string(runes[:cut])) > width {
+				cut--
+			}
+			lines = append(lines, string(runes[:cut]))
+			current = string(runes[cut:])
+		}
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+
+	return lines
+}
+
+// ellipsize shortens a line so that it fits the width with a trailing ellipsis
+func ellipsize(face font.Face, line string, width float64) string {
+	runes := []rune(strings.TrimSpace(line))
+	for len(runes) > 0 && measureText(face, string(runes)+ellipsis) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + ellipsis
+}
+
+// growDocument makes the document taller and adjusts elements marked with data-on-grow
+func growDocument(svgContent string, grow float64) string {
+	// Root element size and viewBox
+	svgContent = rootTagPattern.ReplaceAllStringFunc(svgContent, func(tag string) string {
+		attrs := parseAttrs(tag)
+		if height, err := strconv.ParseFloat(attrs["height"], 64); err == nil {
+			tag = setAttr(tag, "height", formatFloat(height+grow))
+		}
+		if viewBox := strings.Fields(strings.ReplaceAll(attrs["viewBox"], ",", " ")); len(viewBox) == 4 {
+			if height, err := strconv.ParseFloat(viewBox[3], 64); err == nil {
+				viewBox[3] = formatFloat(height + grow)
+				tag = setAttr(tag, "viewBox", strings.Join(viewBox, " "))
+			}
+		}
+		return tag
+	})
+
+	return growTagPattern.ReplaceAllStringFunc(svgContent, func(tag string) string {
+		attrs := parseAttrs(tag)
+		switch attrs[attrOnGrow] {
+		case "stretch":
+			if height, err := strconv.ParseFloat(attrs["height"], 64); err == nil {
+				tag = setAttr(tag, "height", formatFloat(height+grow))
+			}
+		case "move":
+			transform := fmt.Sprintf("translate(0 %s)", formatFloat(grow))
+			if existing := attrs["transform"]; existing != "" {
+				transform += " " + existing
+			}
+			tag = setAttr(tag, "transform", transform)
+		}
+		return tag
+	})
+}
+
+// innerText returns the character data of an element, ignoring nested tags
+func innerText(element string) (string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(element))
+	decoder.Strict = false
+
+	var b strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if data, ok := token.(xml.CharData); ok {
+			b.Write(data)
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " "), nil
+}
+
+// rebuildStartTag writes the text start tag without layout attributes and with the final font size.
+// A font size set in the style attribute is replaced, since style takes precedence over attributes.
+func rebuildStartTag(startTag string, fontSize float64) string {
+	tag := startTag
+	for _, name := range []string{attrBox, attrVAlign, attrLineHeight, attrMaxLines, attrFit, attrMaxGrow, attrMinFontSize} {
+		tag = removeAttr(tag, name)
+	}
+
+	size := formatFloat(fontSize)
+	if style, ok := parseAttrs(tag)["style"]; ok && parseStyle(style)["font-size"] != "" {
+		pattern := regexp.MustCompile(`font-size\s*:\s*[^;"]*`)
+		return setAttr(tag, "style", pattern.ReplaceAllString(style, "font-size: "+size+"px"))
+	}
+	return setAttr(tag, "font-size", size)
+}
+
+// parseAttrs returns the attributes of a start tag
+func parseAttrs(tag string) map[string]string {
+	attrs := make(map[string]string)
+	for _, match := range attrPattern.FindAllStringSubmatch(tag, -1) {
+		attrs[match[1]] = match[2]
+	}
+	return attrs
+}
+
+// parseStyle returns the properties of a style attribute
+func parseStyle(style string) map[string]string {
+	properties := make(map[string]string)
+	for _, declaration := range strings.Split(style, ";") {
+		name, value, ok := strings.Cut(declaration, ":")
+		if !ok {
+			continue
+		}
+		properties[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return properties
+}
+
+// setAttr sets or replaces an attribute on a start tag
+func setAttr(tag, name, value string) string {
+	pattern := regexp.MustCompile(`(\s)` + regexp.QuoteMeta(name) + `\s*=\s*"[^"]*"`)
+	if pattern.MatchString(tag) {
+		return pattern.ReplaceAllString(tag, `${1}`+name+`="`+value+`"`)
+	}
+
+	end := strings.LastIndex(tag, ">")
+	if strings.HasSuffix(tag, "/>") {
+		end = len(tag) - 2
+	}
+	return tag[:end] + fmt.Sprintf(` %s="%s"`, name, value) + tag[end:]
+}
+
+// removeAttr removes an attribute from a start tag
+func removeAttr(tag, name string) string {
+	pattern := regexp.MustCompile(`\s+` + regexp.QuoteMeta(name) + `\s*=\s*"[^"]*"`)
+	return pattern.ReplaceAllString(tag, "")
+}
+
+// floatAttr parses a numeric attribute such as "32" or "32px"
+func floatAttr(attrs map[string]string, name string, fallback float64) (float64, error) {
+	value := strings.TrimSuffix(strings.TrimSpace(attrs[name]), "px")
+	if value == "" {
+		return fallback, nil
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q: %v", name, attrs[name], err)
+	}
+	return v, nil
+}
+
+// formatFloat formats a coordinate rounded to two decimals
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,481 @@
+package printer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"printy/internal/emulator"
+)
+
+// Transport types that can be selected from configuration
+const (
+	TransportCUPS     = "cups"
+	TransportIPP      = "ipp"
+	TransportTCP      = "tcp"
+	TransportDevice   = "device"
+	TransportFile     = "file"
+	TransportMemory   = "memory"
+	TransportEmulator = "emulator"
+)
+
+// DefaultRawPort is the standard JetDirect raw printing port
+const DefaultRawPort = "9100"
+
+// Transport delivers an already encoded ESC/POS job to a printer
+type Transport interface {
+	Send(data []byte) error
+}
+
+// TransportError reports that a job could not be delivered to the printer.
+// Delivery failures are usually transient (printer offline, busy queue) and worth retrying.
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("failed to send job: %v", e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransient reports whether an error from the print pipeline is worth retrying
+func IsTransient(err error) bool {
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) {
+		return false
+	}
+
+	// A job the spooler rejects, such as one sent to an unknown queue, is rejected again
+	var ippErr *IPPError
+	if errors.As(err, &ippErr) {
+		return ippErr.Temporary()
+	}
+	var httpErr *IPPHTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Temporary()
+	}
+	return true
+}
+
+// NewTransport creates a transport from its configured type and address.
+// An empty type defaults to CUPS, where the address is the CUPS queue name.
+func NewTransport(transportType, address string) (Transport, error) {
+	switch strings.ToLower(strings.TrimSpace(transportType)) {
+	case "", TransportCUPS:
+		if address == "" {
+			return nil, fmt.Errorf("cups transport requires a printer queue name")
+		}
+		return &CUPSTransport{Queue: address}, nil
+	case TransportIPP:
+		if address == "" {
+			return nil, fmt.Errorf("ipp transport requires a printer uri")
+		}
+		client, err := NewIPPClient(address)
+		if err != nil {
+			return nil, err
+		}
+		return &IPPTransport{Client: client}, nil
+	case TransportTCP:
+		if address == "" {
+			return nil, fmt.Errorf("tcp transport requires a host address")
+		}
+		return &TCPTransport{Address: address, Timeout: 10 * time.Second}, nil
+	case TransportDevice:
+		if address == "" {
+			return nil, fmt.Errorf("device transport requires a device path")
+		}
+		return &DeviceTransport{Path: address}, nil
+	case TransportFile:
+		if address == "" {
+			return nil, fmt.Errorf("file transport requires a file path")
+		}
+		return &FileTransport{Path: address}, nil
+	case TransportMemory:
+		return &MemoryTransport{}, nil
+	case TransportEmulator:
+		return emulator.New(emulator.DefaultCapacity), nil
+	default:
+		return nil, fmt.Errorf("unknown transport type: %s", transportType)
+	}
+}
+
+// CUPSTransport sends jobs through the CUPS lp command in raw mode
+type CUPSTransport struct {
+	Queue string
+}
+
+// lpRequestID matches the job ID in the output of lp, such as "request id is Office-123 (1 file(s))"
+var lpRequestID = regexp.MustCompile(`request id is (\S+)`)
+
+// Send submits the job to the CUPS queue
+func (t *CUPSTransport) Send(data []byte) error {
+	_, err := t.Submit(data)
+	return err
+}
+
+// Submit submits the job to the CUPS queue and returns the CUPS job ID. A job lp accepted
+// without reporting its ID returns an empty ID, it is printed but cannot be followed.
+func (t *CUPSTransport) Submit(data []byte) (string, error) {
+	cmd := cupsCommand("lp", "-d", t.Queue, "-o", "raw")
+	cmd.Stdin = bytes.NewReader(data)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("lp command failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	// lp exited successfully, so the job is queued and must not be sent again
+	match := lpRequestID.FindSubmatch(output)
+	if match == nil {
+		log.Printf("⚠️  Warning: lp accepted the job for %s without reporting its ID, it will not be tracked: %s", t.Queue, strings.TrimSpace(string(output)))
+		return "", nil
+	}
+
+	return string(match[1]), nil
+}
+
+// cupsCommand prepares a CUPS command line tool in the C locale, since its output is parsed
+func cupsCommand(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C", "LANG=C")
+	return cmd
+}
+
+// JobState looks the job up with lpstat, first among the jobs still queued and then
+// among the completed ones. The job-state-reasons printed as alerts tell held jobs
+// apart from pending ones, and aborted or cancelled jobs apart from completed ones.
+func (t *CUPSTransport) JobState(jobID string) (SpoolState, error) {
+	active, err := t.lpstat("not-completed")
+	if err != nil {
+		return "", err
+	}
+	if reasons, ok := active[jobID]; ok {
+		switch {
+		case hasReason(reasons, "job-hold-until-specified", "job-held"):
+			return SpoolHeld, nil
+		case hasReason(reasons, "job-printing", "job-transforming", "job-outgoing"):
+			return SpoolProcessing, nil
+		default:
+			return SpoolPending, nil
+		}
+	}
+
+	completed, err := t.lpstat("completed")
+	if err != nil {
+		return "", err
+	}
+	if reasons, ok := completed[jobID]; ok {
+		switch {
+		case hasReason(reasons, "job-canceled"):
+			return SpoolCancelled, nil
+		case hasReason(reasons, "job-aborted", "job-completed-with-errors", "aborted-by-system"):
+			return SpoolAborted, nil
+		default:
+			return SpoolCompleted, nil
+		}
+	}
+
+	return SpoolUnknown, nil
+}
+
+// lpstat lists the jobs of the queue that are in the given lpstat -W state,
+// mapped to the job-state-reasons reported as alerts
+func (t *CUPSTransport) lpstat(which string) (map[string][]string, error) {
+	cmd := cupsCommand("lpstat", "-W", which, "-l", "-o", t.Queue)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("lpstat command failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	// Every job starts an unindented line with its ID, the details follow indented
+	jobs := make(map[string][]string)
+	var current string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			current = strings.Fields(line)[0]
+			jobs[current] = nil
+			continue
+		}
+		if reasons, ok := strings.CutPrefix(strings.TrimSpace(line), "Alerts:"); ok && current != "" {
+			jobs[current] = strings.Fields(reasons)
+		}
+	}
+
+	return jobs, nil
+}
+
+// hasReason reports whether any job-state-reason starts with one of the prefixes
+func hasReason(reasons []string, prefixes ...string) bool {
+	for _, reason := range reasons {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(reason, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IPPTransport sends jobs to a CUPS queue or IPP printer in raw mode without the CUPS command line tools
+type IPPTransport struct {
+	Client *IPPClient
+}
+
+// Send submits the job to the printer
+func (t *IPPTransport) Send(data []byte) error {
+	_, err := t.Submit(data)
+	return err
+}
+
+// Submit submits the job with Print-Job and returns the IPP job ID
+func (t *IPPTransport) Submit(data []byte) (string, error) {
+	jobID, err := t.Client.PrintJob("printy", data)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(jobID), nil
+}
+
+// JobState asks for the job state with Get-Job-Attributes
+func (t *IPPTransport) JobState(jobID string) (SpoolState, error) {
+	id, err := strconv.Atoi(jobID)
+	if err != nil {
+		return "", fmt.Errorf("invalid ipp job id %q", jobID)
+	}
+
+	attrs, err := t.Client.GetJobAttributes(id)
+	if err != nil {
+		var ippErr *IPPError
+		if errors.As(err, &ippErr) && ippErr.NotFound() {
+			return SpoolUnknown, nil
+		}
+		return "", err
+	}
+	return attrs.State, nil
+}
+
+// CancelJob cancels a job of the printer with Cancel-Job
+func (t *IPPTransport) CancelJob(jobID string) error {
+	id, err := strconv.Atoi(jobID)
+	if err != nil {
+		return fmt.Errorf("invalid ipp job id %q", jobID)
+	}
+	return t.Client.CancelJob(id)
+}
+
+// PrinterStatus asks for the printer-state-reasons with Get-Printer-Attributes
+func (t *IPPTransport) PrinterStatus() (Status, error) {
+	attrs, err := t.Client.GetPrinterAttributes()
+	if err != nil {
+		return Status{Supported: true, CheckedAt: time.Now()}, err
+	}
+	return ippStatus(attrs), nil
+}
+
+// TCPTransport sends jobs to a network printer listening on a raw port (JetDirect)
+type TCPTransport struct {
+	Address string
+	Timeout time.Duration
+}
+
+// Send opens a connection to the printer and writes the job
+func (t *TCPTransport) Send(data []byte) error {
+	address := t.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, DefaultRawPort)
+	}
+
+	conn, err := net.DialTimeout("tcp", address, t.Timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to printer at %s: %v", address, err)
+	}
+	defer conn.Close()
+
+	if t.Timeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(t.Timeout))
+	}
+
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("failed to write job to %s: %v", address, err)
+	}
+
+	return nil
+}
+
+// Exchange writes a request on a new connection and reads the reply
+func (t *TCPTransport) Exchange(request []byte, replyLen int, timeout time.Duration) ([]byte, error) {
+	address := t.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, DefaultRawPort)
+	}
+
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to printer at %s: %v", address, err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write(request); err != nil {
+		return nil, fmt.Errorf("failed to write request to %s: %v", address, err)
+	}
+
+	reply := make([]byte, replyLen)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, fmt.Errorf("failed to read reply from %s: %v", address, err)
+	}
+
+	return reply, nil
+}
+
+// DeviceTransport writes jobs to a character device such as /dev/usb/lp0
+type DeviceTransport struct {
+	Path string
+}
+
+// Send writes the job to the device
+func (t *DeviceTransport) Send(data []byte) error {
+	device, err := os.OpenFile(t.Path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open printer device %s: %v", t.Path, err)
+	}
+	defer device.Close()
+
+	if _, err := device.Write(data); err != nil {
+		return fmt.Errorf("failed to write job to %s: %v", t.Path, err)
+	}
+
+	return nil
+}
+
+// Exchange writes a request to the device and reads the reply. The device is opened
+// non-blocking so the read gives up at the deadline instead of waiting for a printer that
+// never answers, and the late reply of an earlier request is dropped before asking again.
+func (t *DeviceTransport) Exchange(request []byte, replyLen int, timeout time.Duration) ([]byte, error) {
+	device, err := os.OpenFile(t.Path, os.O_RDWR|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open printer device %s: %v", t.Path, err)
+	}
+	defer device.Close()
+
+	stale := make([]byte, 64)
+	for {
+		if n, err := readDevice(device, stale, time.Now().Add(staleReplyWait)); n == 0 || err != nil {
+			break
+		}
+	}
+
+	if _, err := device.Write(request); err != nil {
+		return nil, fmt.Errorf("failed to write request to %s: %v", t.Path, err)
+	}
+
+	reply := make([]byte, replyLen)
+	if _, err := readDevice(device, reply, time.Now().Add(timeout)); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, fmt.Errorf("printer device %s did not answer within %v", t.Path, timeout)
+		}
+		return nil, fmt.Errorf("failed to read reply from %s: %v", t.Path, err)
+	}
+
+	return reply, nil
+}
+
+// staleReplyWait is how long a device is read for bytes left over from an earlier request
+const staleReplyWait = 20 * time.Millisecond
+
+// devicePollInterval is how often a device that cannot be polled is read again
+const devicePollInterval = 10 * time.Millisecond
+
+// readDevice fills buf from a device opened non-blocking, until the deadline. Devices the
+// runtime cannot poll do not support deadlines and report EAGAIN instead of waiting,
+// so they are read again until the deadline passes.
+func readDevice(device *os.File, buf []byte, deadline time.Time) (int, error) {
+	pollable := device.SetReadDeadline(deadline) == nil
+
+	n := 0
+	for n < len(buf) {
+		m, err := device.Read(buf[n:])
+		n += m
+		switch {
+		case err == nil:
+		case !pollable && errors.Is(err, syscall.EAGAIN):
+			if time.Now().After(deadline) {
+				return n, os.ErrDeadlineExceeded
+			}
+			time.Sleep(devicePollInterval)
+		default:
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// FileTransport appends every job to a file, useful for debugging without a printer
+type FileTransport struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Send appends the job to the file
+func (t *FileTransport) Send(data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	file, err := os.OpenFile(t.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file %s: %v", t.Path, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("failed to write job to %s: %v", t.Path, err)
+	}
+
+	return nil
+}
+
+// MemoryTransport keeps every job in memory, mainly for tests
+type MemoryTransport struct {
+	mu   sync.Mutex
+	jobs [][]byte
+}
+
+// Send stores a copy of the job
+func (t *MemoryTransport) Send(data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job := make([]byte, len(data))
+	copy(job, data)
+	t.jobs = append(t.jobs, job)
+	return nil
+}
+
+// Jobs returns all jobs sent so far
+func (t *MemoryTransport) Jobs() [][]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	jobs := make([][]byte, len(t.jobs))
+	copy(jobs, t.jobs)
+	return jobs
+}
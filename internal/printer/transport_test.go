@@ -0,0 +1,130 @@
+package printer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeCommand puts an executable shell script named name first on the PATH
+func fakeCommand(t *testing.T, name, script string) {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "bin")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestCUPSSubmit(t *testing.T) {
+	received := filepath.Join(t.TempDir(), "job")
+	fakeCommand(t, "lp", `
+[ "$LC_ALL" = C ] || { echo "lp: locale $LC_ALL" >&2; exit 1; }
+cat > `+received+`
+echo "request id is Office-42 (1 file(s))"`)
+
+	transport := &CUPSTransport{Queue: "Office"}
+	jobID, err := transport.Submit([]byte("receipt"))
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if jobID != "Office-42" {
+		t.Errorf("job ID = %q, want Office-42", jobID)
+	}
+
+	data, err := os.ReadFile(received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "receipt" {
+		t.Errorf("lp received %q, want the job data", data)
+	}
+}
+
+func TestCUPSSubmitUnparseableOutput(t *testing.T) {
+	fakeCommand(t, "lp", `cat > /dev/null; echo "Anfrage-ID ist Office-42"`)
+
+	// lp accepted the job, so it must not be reported as a failure worth retrying
+	jobID, err := (&CUPSTransport{Queue: "Office"}).Submit([]byte("receipt"))
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if jobID != "" {
+		t.Errorf("job ID = %q, want an unknown job ID", jobID)
+	}
+}
+
+func TestCUPSSubmitFailure(t *testing.T) {
+	fakeCommand(t, "lp", `cat > /dev/null; echo "lp: The printer or class does not exist." >&2; exit 1`)
+
+	_, err := (&CUPSTransport{Queue: "Missing"}).Submit([]byte("receipt"))
+	if err == nil {
+		t.Fatal("Submit succeeded, want an error")
+	}
+	if !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("error = %v, want the lp output", err)
+	}
+}
+
+func TestCUPSJobState(t *testing.T) {
+	fakeCommand(t, "lpstat", `
+[ "$LC_ALL" = C ] || exit 1
+case "$2" in
+not-completed)
+	cat <<'OUT'
+Office-10               printy          1024   Mon 01 Jan 2024 10:00:00
+	Status: 
+	Alerts: job-hold-until-specified
+	queued for Office
+Office-11               printy          1024   Mon 01 Jan 2024 10:00:01
+	Alerts: job-printing
+	queued for Office
+Office-12               printy          1024   Mon 01 Jan 2024 10:00:02
+	queued for Office
+OUT
+	;;
+completed)
+	cat <<'OUT'
+Office-7                printy          1024   Mon 01 Jan 2024 09:00:00
+	Alerts: job-completed-successfully
+Office-8                printy          1024   Mon 01 Jan 2024 09:00:01
+	Alerts: job-canceled-by-user
+Office-9                printy          1024   Mon 01 Jan 2024 09:00:02
+	Alerts: job-aborted-by-system
+OUT
+	;;
+esac`)
+
+	transport := &CUPSTransport{Queue: "Office"}
+	for jobID, want := range map[string]SpoolState{
+		"Office-10": SpoolHeld,
+		"Office-11": SpoolProcessing,
+		"Office-12": SpoolPending,
+		"Office-7":  SpoolCompleted,
+		"Office-8":  SpoolCancelled,
+		"Office-9":  SpoolAborted,
+		"Office-99": SpoolUnknown,
+	} {
+		state, err := transport.JobState(jobID)
+		if err != nil {
+			t.Errorf("JobState(%s): %v", jobID, err)
+			continue
+		}
+		if state != want {
+			t.Errorf("JobState(%s) = %s, want %s", jobID, state, want)
+		}
+	}
+}
+
+func TestCUPSJobStateFailure(t *testing.T) {
+	fakeCommand(t, "lpstat", `echo "lpstat: No destinations added." >&2; exit 1`)
+
+	if _, err := (&CUPSTransport{Queue: "Office"}).JobState("Office-1"); err == nil {
+		t.Fatal("JobState succeeded, want an error")
+	}
+}
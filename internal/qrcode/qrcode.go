@@ -0,0 +1,556 @@
+// Package qrcode encodes data as QR codes (model 2, byte mode, versions 1 to 10).
+// It draws the raster QR codes embedded in templates and lets the emulator print
+// native GS ( k QR codes like a real printer does.
+package qrcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level is an error correction level
+type Level int
+
+// Error correction levels, recovering about 7%, 15%, 25% and 30% of the code
+const (
+	LevelL Level = iota
+	LevelM
+	LevelQ
+	LevelH
+)
+
+// ParseLevel parses an error correction level written as L, M, Q or H
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case "L":
+		return LevelL, nil
+	case "M":
+		return LevelM, nil
+	case "Q":
+		return LevelQ, nil
+	case "H":
+		return LevelH, nil
+	}
+	return 0, fmt.Errorf("invalid error correction level %q, expected L, M, Q or H", name)
+}
+
+// MaxVersion is the largest version supported, 57x57 modules holding up to 271 bytes at level L
+const MaxVersion = 10
+
+// QuietZone is the number of light modules required around a code
+const QuietZone = 4
+
+// blockSpec describes the error correction blocks of a version and level:
+// codewords of error correction per block, then the number of blocks and their data
+// codewords for the first and the second group
+type blockSpec struct {
+	ecPerBlock   int
+	group1Blocks int
+	group1Data   int
+	group2Blocks int
+	group2Data   int
+}
+
+// blockSpecs holds the block structure of versions 1 to 10, by version and level
+var blockSpecs = [MaxVersion + 1][4]blockSpec{
+	1:  {{7, 1, 19, 0, 0}, {10, 1, 16, 0, 0}, {13, 1, 13, 0, 0}, {17, 1, 9, 0, 0}},
+	2:  {{10, 1, 34, 0, 0}, {16, 1, 28, 0, 0}, {22, 1, 22, 0, 0}, {28, 1, 16, 0, 0}},
+	3:  {{15, 1, 55, 0, 0}, {26, 1, 44, 0, 0}, {18, 2, 17, 0, 0}, {22, 2, 13, 0, 0}},
+	4:  {{20, 1, 80, 0, 0}, {18, 2, 32, 0, 0}, {26, 2, 24, 0, 0}, {16, 4, 9, 0, 0}},
+	5:  {{26, 1, 108, 0, 0}, {24, 2, 43, 0, 0}, {18, 2, 15, 2, 16}, {22, 2, 11, 2, 12}},
+	6:  {{18, 2, 68, 0, 0}, {16, 4, 27, 0, 0}, {24, 4, 19, 0, 0}, {28, 4, 15, 0, 0}},
+	7:  {{20, 2, 78, 0, 0}, {18, 4, 31, 0, 0}, {18, 2, 14, 4, 15}, {26, 4, 13, 1, 14}},
+	8:  {{24, 2, 97, 0, 0}, {22, 2, 38, 2, 39}, {22, 4, 18, 2, 19}, {26, 4, 14, 2, 15}},
+	9:  {{30, 2, 116, 0, 0}, {22, 3, 36, 2, 37}, {20, 4, 16, 4, 17}, {24, 4, 12, 4, 13}},
+	10: {{18, 2, 68, 2, 69}, {26, 4, 43, 1, 44}, {24, 6, 19, 2, 20}, {28, 6, 15, 2, 16}},
+}
+
+// alignmentPositions holds the centers of the alignment patterns by version
+var alignmentPositions = [MaxVersion + 1][]int{
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+// remainderBits is the number of unused bits after the codewords, by version
+var remainderBits = [MaxVersion + 1]int{0, 0, 7, 7, 7, 7, 7, 0, 0, 0, 0}
+
+// formatLevelBits are the error correction bits of the format information
+var formatLevelBits = [4]int{LevelL: 1, LevelM: 0, LevelQ: 3, LevelH: 2}
+
+// Code is an encoded QR code
+type Code struct {
+	Version int
+	Level   Level
+	Size    int // Modules per side, without the quiet zone
+	Mask    int
+
+	modules    [][]bool // Dark modules by row and column
+	isFunction [][]bool // Modules of the finder, timing, alignment and format patterns
+}
+
+// Encode encodes data in byte mode with the smallest version that fits
+func Encode(data []byte, level Level) (*Code, error) {
+	if level < LevelL || level > LevelH {
+		return nil, fmt.Errorf("invalid error correction level %d", level)
+	}
+
+	for version := 1; version <= MaxVersion; version++ {
+		if len(data) <= Capacity(version, level) {
+			return encode(data, version, level), nil
+		}
+	}
+	return nil, fmt.Errorf("%d bytes do not fit in a version %d QR code, at most %d", len(data), MaxVersion, Capacity(MaxVersion, level))
+}
+
+// Capacity returns how many bytes a version holds in byte mode
+func Capacity(version int, level Level) int {
+	spec := blockSpecs[version][level]
+	dataBits := (spec.group1Blocks*spec.group1Data + spec.group2Blocks*spec.group2Data) * 8
+	return (dataBits - 4 - countBits(version)) / 8
+}
+
+// Black reports whether the module at column x and row y is dark.
+// Modules outside the code, in the quiet zone, are light.
+func (c *Code) Black(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y][x]
+}
+
+// countBits returns the length of the byte mode character count
+func countBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// encode builds the code of a version that is known to fit the data
+func encode(data []byte, version int, level Level) *Code {
+	size := version*4 + 17
+	c := &Code{
+		Version:    version,
+		Level:      level,
+		Size:       size,
+		modules:    make([][]bool, size),
+		isFunction: make([][]bool, size),
+	}
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.isFunction[i] = make([]bool, size)
+	}
+
+	c.drawFunctionPatterns()
+	c.drawCodewords(c.codewords(data))
+
+	// Keep the mask that gives the fewest patterns confusing scanners
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if penalty := c.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask) // Masks are XORs, applying one again removes it
+	}
+
+	c.Mask = bestMask
+	c.applyMask(bestMask)
+	c.drawFormatBits(bestMask)
+	return c
+}
+
+// codewords returns the data codewords followed by the error correction codewords, interleaved
+func (c *Code) codewords(data []byte) []byte {
+	spec := blockSpecs[c.Version][c.Level]
+	dataCapacity := spec.group1Blocks*spec.group1Data + spec.group2Blocks*spec.group2Data
+
+	// Mode, character count, data, terminator and padding
+	var bits bitBuffer
+	bits.append(0x4, 4)
+	bits.append(len(data), countBits(c.Version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	bits.append(0, min(4, dataCapacity*8-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < dataCapacity*8; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	codewords := bits.bytes()
+
+	// Split into blocks and compute the error correction of each
+	var blocks, ecBlocks [][]byte
+	divisor := reedSolomonDivisor(spec.ecPerBlock)
+	offset := 0
+	for i := 0; i < spec.group1Blocks+spec.group2Blocks; i++ {
+		n := spec.group1Data
+		if i >= spec.group1Blocks {
+			n = spec.group2Data
+		}
+		block := codewords[offset : offset+n]
+		offset += n
+		blocks = append(blocks, block)
+		ecBlocks = append(ecBlocks, reedSolomonRemainder(block, divisor))
+	}
+
+	// Interleave the data codewords, then the error correction codewords
+	var result []byte
+	for i := 0; i < max(spec.group1Data, spec.group2Data); i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < spec.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+// drawFunctionPatterns draws the patterns that are not data and reserves the format areas
+func (c *Code) drawFunctionPatterns() {
+	// Timing patterns
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns with their separators, in three corners
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	// Alignment patterns, except where they would overlap a finder
+	positions := alignmentPositions[c.Version]
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignment(x, y)
+		}
+	}
+
+	// Reserve the format areas, drawn for real once the mask is chosen
+	c.drawFormatBits(0)
+	c.drawVersionBits()
+}
+
+// drawFinder draws a finder pattern and its separator around the center x, y
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawAlignment draws an alignment pattern around the center x, y
+func (c *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormatBits draws both copies of the level and mask information, and the dark module
+func (c *Code) drawFormatBits(mask int) {
+	data := formatLevelBits[c.Level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	// Around the top left finder
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(bits, i))
+	}
+	c.setFunction(8, 7, bit(bits, 6))
+	c.setFunction(8, 8, bit(bits, 7))
+	c.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	// Next to the top right and bottom left finders
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(bits, i))
+	}
+	c.setFunction(8, c.Size-8, true)
+}
+
+// drawVersionBits draws both copies of the version information of versions 7 and up
+func (c *Code) drawVersionBits() {
+	if c.Version < 7 {
+		return
+	}
+
+	rem := c.Version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := c.Version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, bit(bits, i))
+		c.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// drawCodewords places the codewords in the two-column zigzag from the bottom right corner
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	total := len(codewords)*8 + remainderBits[c.Version]
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// Skip the vertical timing pattern
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.isFunction[y][x] || i >= total {
+					continue
+				}
+				// Remainder bits are light
+				if i < len(codewords)*8 {
+					c.modules[y][x] = bit(int(codewords[i>>3]), 7-i&7)
+				}
+				i++
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules selected by a mask pattern
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.isFunction[y][x] {
+				continue
+			}
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the patterns of the code that make it harder to scan, lower is better
+func (c *Code) penalty() int {
+	penalty := 0
+	dark := 0
+
+	for i := 0; i < c.Size; i++ {
+		rowRun, colRun := 1, 1
+		for j := 0; j < c.Size; j++ {
+			if c.modules[i][j] {
+				dark++
+			}
+			if j == 0 {
+				continue
+			}
+
+			// Runs of five or more modules of the same color
+			if c.modules[i][j] == c.modules[i][j-1] {
+				rowRun++
+			} else {
+				rowRun = 1
+			}
+			if rowRun == 5 {
+				penalty += 3
+			} else if rowRun > 5 {
+				penalty++
+			}
+			if c.modules[j][i] == c.modules[j-1][i] {
+				colRun++
+			} else {
+				colRun = 1
+			}
+			if colRun == 5 {
+				penalty += 3
+			} else if colRun > 5 {
+				penalty++
+			}
+
+			// Blocks of two by two modules of the same color
+			if i > 0 {
+				v := c.modules[i][j]
+				if v == c.modules[i][j-1] && v == c.modules[i-1][j] && v == c.modules[i-1][j-1] {
+					penalty += 3
+				}
+			}
+		}
+
+		// Patterns that look like a finder, dark-light-dark-dark-dark-light-dark with light around
+		for j := 0; j+11 <= c.Size; j++ {
+			if matchesFinder(func(k int) bool { return c.modules[i][j+k] }) {
+				penalty += 40
+			}
+			if matchesFinder(func(k int) bool { return c.modules[j+k][i] }) {
+				penalty += 40
+			}
+		}
+	}
+
+	// Balance of dark and light modules
+	total := c.Size * c.Size
+	deviation := abs(dark*20-total*10) / total
+	penalty += deviation * 10
+
+	return penalty
+}
+
+// matchesFinder reports whether 11 modules are a finder-like pattern with four light modules
+// on one side
+func matchesFinder(at func(int) bool) bool {
+	core := [7]bool{true, false, true, true, true, false, true}
+	before, after := true, true
+	for k := 0; k < 11; k++ {
+		// Core after four light modules
+		if k < 4 {
+			before = before && !at(k)
+		} else if before && at(k) != core[k-4] {
+			before = false
+		}
+		// Core followed by four light modules
+		if k < 7 {
+			if after && at(k) != core[k] {
+				after = false
+			}
+		} else {
+			after = after && !at(k)
+		}
+	}
+	return before || after
+}
+
+// setFunction sets a module that is part of a function pattern
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.isFunction[y][x] = true
+}
+
+// bitBuffer is a sequence of bits
+type bitBuffer []bool
+
+// append adds the n low bits of value, most significant first
+func (b *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (value>>i)&1 != 0)
+	}
+}
+
+// bytes packs the bits into bytes
+func (b bitBuffer) bytes() []byte {
+	result := make([]byte, (len(b)+7)/8)
+	for i, v := range b {
+		if v {
+			result[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+	return result
+}
+
+// reedSolomonDivisor returns the generator polynomial of the given degree, without its leading term
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// reedSolomonRemainder returns the error correction codewords of a block
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies two elements of GF(256) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// bit returns bit i of x
+func bit(x, i int) bool {
+	return (x>>i)&1 != 0
+}
+
+// abs returns the absolute value of x
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,64 @@
+package queue
+
+import (
+	"sync"
+
+	"printy/internal/db"
+)
+
+// subscriberBuffer is how many events a slow subscriber can fall behind before events are dropped
+const subscriberBuffer = 32
+
+// Broker fans job events out to live subscribers such as SSE streams
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[int]map[chan db.JobEvent]struct{}
+}
+
+// NewBroker creates an event broker
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[int]map[chan db.JobEvent]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving new events of a job and a function to unsubscribe
+func (b *Broker) Subscribe(jobID int) (<-chan db.JobEvent, func()) {
+	ch := make(chan db.JobEvent, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[jobID] == nil {
+		b.subscribers[jobID] = make(map[chan db.JobEvent]struct{})
+	}
+	b.subscribers[jobID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			delete(b.subscribers[jobID], ch)
+			if len(b.subscribers[jobID]) == 0 {
+				delete(b.subscribers, jobID)
+			}
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish delivers an event to every subscriber of its job without blocking
+func (b *Broker) Publish(event db.JobEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[event.JobID] {
+		select {
+		case ch <- event:
+		default:
+			// Subscriber is not keeping up, SSE streams replay missed events from the database
+		}
+	}
+}
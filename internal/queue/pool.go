@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"fmt"
+
+	"printy/internal/db"
+)
+
+// Pool holds one worker per registered printer. The first printer added is the default one.
+type Pool struct {
+	workers map[string]*Worker
+	names   []string // Printer names in the order they were added
+}
+
+// NewPool creates an empty pool
+func NewPool() *Pool {
+	return &Pool{workers: make(map[string]*Worker)}
+}
+
+// Add registers the worker of a printer
+func (p *Pool) Add(w *Worker) error {
+	name := w.PrinterName()
+	if _, ok := p.workers[name]; ok {
+		return fmt.Errorf("printer %s is already registered", name)
+	}
+	p.workers[name] = w
+	p.names = append(p.names, name)
+	return nil
+}
+
+// SetFallback makes the fallback printer take the jobs the named printer cannot deliver
+func (p *Pool) SetFallback(printerName, fallbackName string) error {
+	w, ok := p.workers[printerName]
+	if !ok {
+		return fmt.Errorf("printer not found: %s", printerName)
+	}
+	fallback, ok := p.workers[fallbackName]
+	if !ok {
+		return fmt.Errorf("printer not found: %s", fallbackName)
+	}
+	w.SetFallback(fallback)
+	return nil
+}
+
+// Has reports whether a printer is registered
+func (p *Pool) Has(printerName string) bool {
+	_, ok := p.workers[printerName]
+	return ok
+}
+
+// Default returns the worker of the default printer
+func (p *Pool) Default() *Worker {
+	if len(p.names) == 0 {
+		return nil
+	}
+	return p.workers[p.names[0]]
+}
+
+// Worker returns the worker of a printer, or the default worker when the name is empty
+func (p *Pool) Worker(printerName string) (*Worker, error) {
+	if printerName == "" {
+		if w := p.Default(); w != nil {
+			return w, nil
+		}
+		return nil, fmt.Errorf("no printers registered")
+	}
+
+	w, ok := p.workers[printerName]
+	if !ok {
+		return nil, fmt.Errorf("printer not found: %s", printerName)
+	}
+	return w, nil
+}
+
+// Workers returns every worker in the order the printers were added
+func (p *Pool) Workers() []*Worker {
+	workers := make([]*Worker, 0, len(p.names))
+	for _, name := range p.names {
+		workers = append(workers, p.workers[name])
+	}
+	return workers
+}
+
+// Enqueue stores a job for the named printer, or the default printer when the name is empty
+func (p *Pool) Enqueue(job *db.Job, printerName string) error {
+	w, err := p.Worker(printerName)
+	if err != nil {
+		return err
+	}
+	return w.Enqueue(job)
+}
+
+// Start starts every worker
+func (p *Pool) Start() {
+	for _, w := range p.Workers() {
+		w.Start()
+	}
+}
+
+// Stop stops every worker after their current job
+func (p *Pool) Stop() {
+	for _, w := range p.Workers() {
+		w.Stop()
+	}
+}
@@ -0,0 +1,518 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"printy/internal/db"
+	"printy/internal/printer"
+	"printy/internal/tickets"
+)
+
+const (
+	// DefaultMaxAttempts is how many times a job is tried before it is marked as failed
+	DefaultMaxAttempts = 5
+
+	// pollInterval is how often the worker checks for due retries without being notified
+	pollInterval = 5 * time.Second
+
+	// baseRetryDelay is the delay before the first retry, doubled on every attempt
+	baseRetryDelay = 10 * time.Second
+
+	// maxRetryDelay caps the exponential backoff
+	maxRetryDelay = 10 * time.Minute
+)
+
+// StageRecord is the pipeline stage that writes the print record
+const StageRecord = "record"
+
+// Worker drains the job queue of a single printer
+type Worker struct {
+	database *db.Database
+	printer  *printer.Printer
+	events   *Broker
+	fallback *Worker // Takes the jobs this printer cannot deliver, nil when there is none
+	held     bool    // Whether jobs may be held waiting for the printer, only used by the worker loop
+
+	wake     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewWorker creates a worker for the given printer that reports progress to the broker
+func NewWorker(database *db.Database, p *printer.Printer, events *Broker) *Worker {
+	return &Worker{
+		database: database,
+		printer:  p,
+		events:   events,
+		held:     true, // Jobs held before a restart are checked on the first pass
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// SetFallback sets the worker that takes the jobs this worker's printer cannot deliver
+func (w *Worker) SetFallback(fallback *Worker) {
+	w.fallback = fallback
+}
+
+// Printer returns the printer this worker drains
+func (w *Worker) Printer() *printer.Printer {
+	return w.printer
+}
+
+// PrinterName returns the name of the printer this worker drains
+func (w *Worker) PrinterName() string {
+	return w.printer.GetPrinterName()
+}
+
+// Start starts processing jobs in the background
+func (w *Worker) Start() {
+	go w.run()
+}
+
+// Stop stops the worker after the current job finishes
+func (w *Worker) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+// Notify wakes the worker up to check for new jobs
+func (w *Worker) Notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+		// A wake up is already pending
+	}
+}
+
+// Enqueue stores a new job for this worker's printer and wakes the worker up
+func (w *Worker) Enqueue(job *db.Job) error {
+	job.Printer = w.PrinterName()
+	job.Status = db.JobStatusQueued
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = DefaultMaxAttempts
+	}
+
+	if err := w.database.CreateJob(job); err != nil {
+		return err
+	}
+	w.emit(job.ID, db.JobEventStageJob, db.JobStatusQueued, "", 0)
+
+	w.Notify()
+	return nil
+}
+
+// run is the worker loop
+func (w *Worker) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.drain()
+
+		select {
+		case <-w.stop:
+			return
+		case <-w.wake:
+		case <-ticker.C:
+			w.trackSpooled()
+		}
+	}
+}
+
+// drain processes due jobs until the queue is empty or the worker is stopped.
+// Nothing is printed while jobs are held waiting for the printer to recover.
+func (w *Worker) drain() {
+	if w.held && !w.releaseHeld() {
+		return
+	}
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		job, err := w.database.ClaimNextJob(w.PrinterName())
+		if err != nil {
+			log.Printf("❌ Failed to claim next job for %s: %v", w.PrinterName(), err)
+			return
+		}
+		if job == nil {
+			return
+		}
+
+		w.process(job)
+		if w.held {
+			// The printer reported a problem, the next jobs wait for it too
+			return
+		}
+	}
+}
+
+// process runs a claimed job through the print pipeline
+func (w *Worker) process(job *db.Job) {
+	startTime := time.Now()
+	log.Printf("🖨️  Starting print job %d (attempt %d/%d)", job.ID, job.Attempts, job.MaxAttempts)
+	w.emit(job.ID, db.JobEventStageJob, db.JobStatusRendering, fmt.Sprintf("attempt %d of %d", job.Attempts, job.MaxAttempts), 0)
+
+	err := w.execute(job)
+	if err == nil {
+		if err := w.database.UpdateJobStatus(job.ID, db.JobStatusDone); err != nil {
+			log.Printf("⚠️  Warning: Failed to mark job %d as done: %v", job.ID, err)
+		}
+		printDuration := time.Since(startTime)
+		log.Printf("✅ Print job %d completed in %v", job.ID, printDuration)
+		w.emit(job.ID, db.JobEventStageJob, db.JobStatusDone, "", printDuration)
+		return
+	}
+
+	// A printer that reports a problem keeps its jobs until it recovers,
+	// unless another printer can take them
+	var statusErr *printer.StatusError
+	if errors.As(err, &statusErr) {
+		if !w.moveToFallback(job, err) {
+			w.hold(job, err)
+		}
+		return
+	}
+
+	if printer.IsTransient(err) && w.moveToFallback(job, err) {
+		return
+	}
+
+	if printer.IsTransient(err) && job.Attempts < job.MaxAttempts {
+		nextAttempt := time.Now().Add(retryDelay(job.Attempts))
+		log.Printf("⚠️  Print job %d failed, retrying at %s: %v", job.ID, nextAttempt.Format("15:04:05"), err)
+		if err := w.database.RetryJob(job.ID, err.Error(), nextAttempt); err != nil {
+			log.Printf("❌ Failed to reschedule job %d: %v", job.ID, err)
+		}
+		w.emit(job.ID, db.JobEventStageJob, db.JobStatusQueued, fmt.Sprintf("retrying at %s: %v", nextAttempt.Format(time.RFC3339), err), 0)
+		return
+	}
+
+	log.Printf("❌ Print job %d failed: %v", job.ID, err)
+	if err := w.database.FailJob(job.ID, err.Error()); err != nil {
+		log.Printf("❌ Failed to mark job %d as failed: %v", job.ID, err)
+	}
+	w.emit(job.ID, db.JobEventStageJob, db.JobStatusFailed, err.Error(), time.Since(startTime))
+}
+
+// moveToFallback hands a job that failed on this printer to the fallback printer.
+// A job is moved once, so it reports false when the job already came from another printer.
+func (w *Worker) moveToFallback(job *db.Job, err error) bool {
+	if w.fallback == nil || job.FallbackFrom != "" {
+		return false
+	}
+
+	fallbackName := w.fallback.PrinterName()
+	log.Printf("🔀 Print job %d failed on %s, moving it to %s: %v", job.ID, w.PrinterName(), fallbackName, err)
+	if moveErr := w.database.FallbackJob(job.ID, fallbackName, err.Error()); moveErr != nil {
+		log.Printf("❌ Failed to move job %d to %s: %v", job.ID, fallbackName, moveErr)
+		return false
+	}
+
+	w.emit(job.ID, db.JobEventStageJob, db.JobStatusQueued, fmt.Sprintf("moved to printer %s: %v", fallbackName, err), 0)
+	w.fallback.Notify()
+	return true
+}
+
+// hold parks a job until the printer recovers
+func (w *Worker) hold(job *db.Job, err error) {
+	log.Printf("✋ Holding print job %d until %s recovers: %v", job.ID, w.PrinterName(), err)
+	if err := w.database.HoldJob(job.ID, err.Error()); err != nil {
+		log.Printf("❌ Failed to hold job %d: %v", job.ID, err)
+		return
+	}
+	w.held = true
+	w.emit(job.ID, db.JobEventStageJob, db.JobStatusHeld, err.Error(), 0)
+}
+
+// releaseHeld puts held jobs back in the queue once the printer reports it is ready.
+// It returns false while the printer still reports a problem.
+func (w *Worker) releaseHeld() bool {
+	status, err := w.printer.Status()
+	if err == nil && !status.Ready() {
+		return false
+	}
+
+	released, err := w.database.ReleaseHeldJobs(w.PrinterName())
+	if err != nil {
+		log.Printf("❌ Failed to release held jobs of %s: %v", w.PrinterName(), err)
+		return false
+	}
+	if released > 0 {
+		log.Printf("▶️  %s is ready again, released %d held jobs", w.PrinterName(), released)
+	}
+
+	w.held = false
+	return true
+}
+
+// execute renders and prints a job and records its prints. The prints are created before
+// rendering so their codes can be printed, and attempts that fail to render or print are
+// recorded as failed prints.
+func (w *Worker) execute(job *db.Job) error {
+	payload, err := job.GetPayload()
+	if err != nil {
+		return fmt.Errorf("invalid job payload: %v", err)
+	}
+
+	onStage := w.stageReporter(job.ID)
+	if err := w.printer.CheckReady(onStage); err != nil {
+		return err
+	}
+
+	prints := w.startPrints(job, payload)
+	outcome := printOutcome{template: payload.Template}
+	err = w.print(job, payload, prints, &outcome, onStage)
+	w.finishPrints(job, prints, outcome, err)
+	return err
+}
+
+// print renders a job unless it is already encoded and sends it to the printer, filling in
+// the outcome of the attempt as it goes
+func (w *Worker) print(job *db.Job, payload db.JobPayload, prints []*db.Print, outcome *printOutcome, onStage printer.StageFunc) error {
+	artifacts := w.printer.Artifacts(job.ID)
+	if dir := artifacts.Dir(); dir != "" {
+		log.Printf("🗂️  Keeping artifacts of job %d in %s", job.ID, dir)
+	}
+
+	// Raw jobs and reprints of stored data are already encoded, every other job is rendered
+	// from its template
+	var rendered *printer.Rendered
+	var err error
+	raster := printer.RasterOptions{
+		Dither:    payload.Dither,
+		Threshold: payload.Threshold,
+		Gamma:     payload.Gamma,
+		Contrast:  payload.Contrast,
+	}
+	renderStart := time.Now()
+	switch {
+	case job.Kind == db.JobKindRaw || len(payload.Data) > 0:
+		// Raw data and the kept data of a reprint were encoded for the printer they were sent
+		// to, which may not be this one when the job was moved to a fallback printer
+		if err = printer.ValidateRaw(payload.Data, w.printer.Profile()); err != nil {
+			err = fmt.Errorf("%s job cannot be printed on %s: %v", job.Kind, w.PrinterName(), err)
+		}
+	case job.Kind == db.JobKindAgenda:
+		items := make([]printer.ListItem, 0, len(payload.Tickets))
+		for _, ticket := range payload.Tickets {
+			items = append(items, printer.NewListItem(ticket.RefID, ticket.Title, ticket.Assignee, ticket.Priority))
+		}
+		rendered, err = w.printer.RenderList(payload.Template, payload.Assignee, items, raster, artifacts, onStage)
+	case job.Kind == db.JobKindReport:
+		var report printer.Report
+		if report, err = tickets.WeeklyReport(w.database, job.CreatedAt, payload.Assignee); err == nil {
+			rendered, err = w.printer.RenderReport(payload.Template, report, raster, artifacts, onStage)
+		}
+	default:
+		ticket := printer.Ticket{
+			RefID:       payload.RefID,
+			Title:       payload.Title,
+			Assignee:    payload.Assignee,
+			URL:         payload.URL,
+			CompleteURL: tickets.CompletionURL(payload.RefID),
+		}
+		if len(prints) == 1 && job.TicketID != 0 {
+			// Scanning the code completes the ticket
+			ticket.PrintCode = tickets.PrintCode(prints[0].ID)
+		}
+		rendered, err = w.printer.Render(payload.Template, ticket, raster, artifacts, onStage)
+	}
+	outcome.render = time.Since(renderStart)
+	if err != nil {
+		return err
+	}
+	if rendered != nil {
+		outcome.template = rendered.Template
+		outcome.imageHash = w.printer.ImageHash(rendered)
+	}
+
+	if err := w.database.UpdateJobStatus(job.ID, db.JobStatusPrinting); err != nil {
+		return err
+	}
+	w.emit(job.ID, db.JobEventStageJob, db.JobStatusPrinting, "", 0)
+
+	transmitStart := time.Now()
+	if rendered != nil {
+		outcome.spoolJobID, err = w.printer.PrintRendered(rendered, artifacts, onStage)
+	} else {
+		outcome.spoolJobID, err = w.printer.PrintRaw(payload.Data, artifacts, onStage)
+	}
+	outcome.transmit = time.Since(transmitStart)
+	if err != nil {
+		return err
+	}
+	if outcome.spoolJobID != "" {
+		log.Printf("📨 Print job %d accepted by the spooler of %s as %s", job.ID, w.PrinterName(), outcome.spoolJobID)
+	}
+	return nil
+}
+
+// printOutcome is what an attempt to print a job produced, shared by its print records
+type printOutcome struct {
+	template   string // Template actually used, the requested one until it is rendered
+	imageHash  string
+	spoolJobID string
+	render     time.Duration
+	transmit   time.Duration
+}
+
+// startPrints creates the print records of an attempt before it is printed: one for every
+// ticket of an agenda and one for any other job, without a ticket for raw jobs, reports
+// and prints that were not sent from a ticket. A record that cannot be created is left out,
+// the job still prints.
+func (w *Worker) startPrints(job *db.Job, payload db.JobPayload) []*db.Print {
+	var prints []*db.Print
+	start := func(ticketID int, title, assignee string) {
+		print := &db.Print{
+			TicketID:   ticketID,
+			Kind:       job.Kind,
+			Source:     payload.Source,
+			Printer:    w.PrinterName(),
+			Status:     db.PrintStatusPrinting,
+			JobID:      job.ID,
+			Template:   payload.Template,
+			Title:      title,
+			Assignee:   assignee,
+			ReprintOf:  payload.ReprintOf,
+			NoCooldown: payload.ReprintOf != 0 && !payload.ResetCooldown,
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
+		if err := w.database.CreatePrint(print); err != nil {
+			log.Printf("⚠️  Warning: Failed to record print of job %d: %v", job.ID, err)
+			w.emit(job.ID, StageRecord, db.JobEventFailed, err.Error(), 0)
+			return
+		}
+		prints = append(prints, print)
+	}
+
+	if job.Kind == db.JobKindAgenda {
+		for _, ticket := range payload.Tickets {
+			start(ticket.TicketID, ticket.Title, ticket.Assignee)
+		}
+	} else {
+		start(job.TicketID, payload.Title, payload.Assignee)
+	}
+	return prints
+}
+
+// finishPrints records the outcome of an attempt on its prints, failed when printErr is set
+func (w *Worker) finishPrints(job *db.Job, prints []*db.Print, outcome printOutcome, printErr error) {
+	for _, print := range prints {
+		recordStart := time.Now()
+		print.Status = db.PrintStatusSucceeded
+		print.SpoolJobID = outcome.spoolJobID
+		print.Template = outcome.template
+		print.ImageHash = outcome.imageHash
+		print.RenderMs = outcome.render.Milliseconds()
+		print.TransmitMs = outcome.transmit.Milliseconds()
+		if printErr != nil {
+			print.Status = db.PrintStatusFailed
+			print.Error = printErr.Error()
+		}
+		if outcome.spoolJobID != "" {
+			// Followed by trackSpooled until the spooler finishes the job
+			print.SpoolState = string(printer.SpoolPending)
+		}
+		if err := w.database.FinishPrint(print); err != nil {
+			log.Printf("⚠️  Warning: Failed to record print %d of job %d: %v", print.ID, job.ID, err)
+			w.emit(job.ID, StageRecord, db.JobEventFailed, err.Error(), time.Since(recordStart))
+			continue
+		}
+		w.emit(job.ID, StageRecord, db.JobEventCompleted, fmt.Sprintf("%s print %d", print.Status, print.ID), time.Since(recordStart))
+	}
+}
+
+// trackSpooled asks the spooler for the state of the prints it has not finished yet.
+// A print only counts as printed once the spooler completes it.
+func (w *Worker) trackSpooled() {
+	prints, err := w.database.GetSpooledPrints(w.PrinterName())
+	if err != nil {
+		log.Printf("❌ Failed to load spooled prints of %s: %v", w.PrinterName(), err)
+		return
+	}
+
+	for _, print := range prints {
+		state, err := w.printer.SpoolState(print.SpoolJobID)
+		if err != nil {
+			log.Printf("⚠️  Warning: Could not read the state of %s on %s: %v", print.SpoolJobID, w.PrinterName(), err)
+			continue
+		}
+		if string(state) == print.SpoolState {
+			continue
+		}
+
+		if err := w.database.UpdatePrintSpoolState(print.ID, string(state)); err != nil {
+			log.Printf("❌ Failed to update print %d: %v", print.ID, err)
+			continue
+		}
+
+		switch state {
+		case printer.SpoolCompleted:
+			log.Printf("✅ Print %d (%s) completed on %s", print.ID, print.SpoolJobID, w.PrinterName())
+		case printer.SpoolAborted, printer.SpoolCancelled:
+			log.Printf("❌ Print %d (%s) was %s on %s", print.ID, print.SpoolJobID, state, w.PrinterName())
+		case printer.SpoolUnknown:
+			log.Printf("⚠️  Print %d (%s) is no longer known to the spooler of %s", print.ID, print.SpoolJobID, w.PrinterName())
+		default:
+			log.Printf("📨 Print %d (%s) is %s on %s", print.ID, print.SpoolJobID, state, w.PrinterName())
+		}
+	}
+}
+
+// stageReporter returns a printer.StageFunc that records stages as job events
+func (w *Worker) stageReporter(jobID int) printer.StageFunc {
+	return func(stage printer.Stage, elapsed time.Duration, err error) {
+		if err != nil {
+			w.emit(jobID, string(stage), db.JobEventFailed, err.Error(), elapsed)
+			return
+		}
+		log.Printf("⏱️  Job %d %s took %v", jobID, stage, elapsed)
+		w.emit(jobID, string(stage), db.JobEventCompleted, "", elapsed)
+	}
+}
+
+// emit stores a job event and publishes it to live subscribers
+func (w *Worker) emit(jobID int, stage, status, message string, elapsed time.Duration) {
+	event := db.JobEvent{
+		JobID:      jobID,
+		Stage:      stage,
+		Status:     status,
+		Message:    message,
+		DurationMs: elapsed.Milliseconds(),
+	}
+	if err := w.database.CreateJobEvent(&event); err != nil {
+		log.Printf("⚠️  Warning: Failed to record event for job %d: %v", jobID, err)
+	}
+
+	if w.events != nil {
+		w.events.Publish(event)
+	}
+}
+
+// retryDelay returns the exponential backoff delay after the given attempt
+func retryDelay(attempt int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,210 @@
+package rawprint
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"printy/internal/db"
+	"printy/internal/printer"
+	"printy/internal/queue"
+)
+
+const (
+	// DefaultMaxJobBytes caps the size of a raw job, enough for a long receipt of raster images
+	DefaultMaxJobBytes = 1 << 20
+
+	// DefaultIdleTimeout ends a job when the client stops sending without closing the connection
+	DefaultIdleTimeout = 10 * time.Second
+
+	// maxConnections is how many clients may send jobs at the same time
+	maxConnections = 8
+)
+
+// Config configures the raw job listener
+type Config struct {
+	Address     string        // Listen address such as :9100, empty to disable the listener
+	Printer     string        // Printer the jobs are queued for, empty for the default printer
+	MaxJobBytes int           // Larger jobs are refused
+	IdleTimeout time.Duration // Silence that ends a job
+}
+
+// LoadConfig reads the listener configuration from RAW_LISTEN_ADDR, RAW_PRINTER,
+// RAW_MAX_JOB_BYTES and RAW_IDLE_TIMEOUT
+func LoadConfig() (Config, error) {
+	config := Config{
+		Address:     strings.TrimSpace(os.Getenv("RAW_LISTEN_ADDR")),
+		Printer:     strings.TrimSpace(os.Getenv("RAW_PRINTER")),
+		MaxJobBytes: DefaultMaxJobBytes,
+		IdleTimeout: DefaultIdleTimeout,
+	}
+
+	if raw := strings.TrimSpace(os.Getenv("RAW_MAX_JOB_BYTES")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			return Config{}, fmt.Errorf("invalid RAW_MAX_JOB_BYTES %q", raw)
+		}
+		config.MaxJobBytes = v
+	}
+
+	if raw := strings.TrimSpace(os.Getenv("RAW_IDLE_TIMEOUT")); raw != "" {
+		v, err := time.ParseDuration(raw)
+		if err != nil || v <= 0 {
+			return Config{}, fmt.Errorf("invalid RAW_IDLE_TIMEOUT %q", raw)
+		}
+		config.IdleTimeout = v
+	}
+
+	return config, nil
+}
+
+// Listener accepts raw ESC/POS jobs on a TCP port, like the JetDirect port of a network
+// printer. Jobs are checked, then queued for a printer like ticket jobs.
+type Listener struct {
+	config   Config
+	pool     *queue.Pool
+	worker   *queue.Worker
+	listener net.Listener
+	slots    chan struct{} // Limits concurrent connections
+	wg       sync.WaitGroup
+}
+
+// New creates a listener that queues jobs for the configured printer of the pool
+func New(config Config, pool *queue.Pool) (*Listener, error) {
+	worker, err := pool.Worker(config.Printer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RAW_PRINTER: %v", err)
+	}
+
+	return &Listener{
+		config: config,
+		pool:   pool,
+		worker: worker,
+		slots:  make(chan struct{}, maxConnections),
+	}, nil
+}
+
+// Start listens on the configured address and accepts jobs in the background
+func (l *Listener) Start() error {
+	listener, err := net.Listen("tcp", l.config.Address)
+	if err != nil {
+		return fmt.Errorf("failed to listen for raw jobs on %s: %v", l.config.Address, err)
+	}
+	l.listener = listener
+
+	log.Printf("📡 Accepting raw ESC/POS jobs for %s on %s (up to %d bytes)", l.worker.PrinterName(), listener.Addr(), l.config.MaxJobBytes)
+
+	l.wg.Add(1)
+	go l.accept()
+	return nil
+}
+
+// Close stops accepting jobs and waits for the connections being read
+func (l *Listener) Close() error {
+	if l.listener == nil {
+		return nil
+	}
+	err := l.listener.Close()
+	l.wg.Wait()
+	return err
+}
+
+// accept is the accept loop
+func (l *Listener) accept() {
+	defer l.wg.Done()
+
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("❌ Failed to accept raw job connection: %v", err)
+			continue
+		}
+
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			log.Printf("🚫 Refused raw job from %s: too many connections", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			defer func() { <-l.slots }()
+			defer conn.Close()
+			l.handle(conn)
+		}()
+	}
+}
+
+// handle reads one job from a connection and queues it. The job ends when the client
+// closes the connection or stops sending for the idle timeout.
+func (l *Listener) handle(conn net.Conn) {
+	source := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(source); err == nil {
+		source = host
+	}
+
+	data, err := l.read(conn)
+	if err != nil {
+		log.Printf("🚫 Refused raw job from %s: %v", source, err)
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	if err := printer.ValidateRaw(data, l.worker.Printer().Profile()); err != nil {
+		log.Printf("🚫 Refused raw job from %s: %v", source, err)
+		return
+	}
+
+	job := &db.Job{Kind: db.JobKindRaw}
+	if err := job.SetPayload(db.JobPayload{Data: data, Source: source}); err != nil {
+		log.Printf("❌ Failed to encode raw job from %s: %v", source, err)
+		return
+	}
+	if err := l.pool.Enqueue(job, l.worker.PrinterName()); err != nil {
+		log.Printf("❌ Failed to enqueue raw job from %s: %v", source, err)
+		return
+	}
+
+	log.Printf("📥 Queued raw job %d from %s (%d bytes)", job.ID, source, len(data))
+}
+
+// read reads a job up to the maximum size
+func (l *Listener) read(conn net.Conn) ([]byte, error) {
+	var data []byte
+	buf := make([]byte, 32*1024)
+	for {
+		conn.SetReadDeadline(time.Now().Add(l.config.IdleTimeout))
+		n, err := conn.Read(buf)
+		data = append(data, buf[:n]...)
+		if len(data) > l.config.MaxJobBytes {
+			return nil, fmt.Errorf("job is larger than %d bytes", l.config.MaxJobBytes)
+		}
+
+		var netErr net.Error
+		switch {
+		case err == nil:
+		case err == io.EOF:
+			return data, nil
+		case errors.As(err, &netErr) && netErr.Timeout():
+			// Clients that keep the connection open are done once they stop sending
+			return data, nil
+		default:
+			return nil, fmt.Errorf("failed to read job: %v", err)
+		}
+	}
+}
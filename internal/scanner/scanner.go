@@ -0,0 +1,197 @@
+// Package scanner reads the codes of a keyboard-wedge barcode scanner, one per line,
+// and marks the scanned tickets as done.
+package scanner
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"printy/internal/db"
+	"printy/internal/tickets"
+)
+
+const (
+	// StdinInput is the SCANNER_INPUT value that reads codes from the standard input
+	StdinInput = "-"
+
+	// DefaultDuplicateWindow ignores a code scanned again within this time, since scanners
+	// often read a barcode twice
+	DefaultDuplicateWindow = 10 * time.Second
+
+	// reopenDelay is the pause before opening a pipe or device again after it was closed
+	reopenDelay = time.Second
+)
+
+// Config configures the scanner reader
+type Config struct {
+	Input           string        // Line-oriented device, named pipe or file, "-" for stdin, empty to disable
+	DuplicateWindow time.Duration // Repeated codes within this time are ignored
+}
+
+// LoadConfig reads the scanner configuration from SCANNER_INPUT and SCANNER_DUPLICATE_WINDOW
+func LoadConfig() (Config, error) {
+	config := Config{
+		Input:           strings.TrimSpace(os.Getenv("SCANNER_INPUT")),
+		DuplicateWindow: DefaultDuplicateWindow,
+	}
+
+	if raw := strings.TrimSpace(os.Getenv("SCANNER_DUPLICATE_WINDOW")); raw != "" {
+		v, err := time.ParseDuration(raw)
+		if err != nil || v < 0 {
+			return Config{}, fmt.Errorf("invalid SCANNER_DUPLICATE_WINDOW %q", raw)
+		}
+		config.DuplicateWindow = v
+	}
+
+	return config, nil
+}
+
+// Reader records a completion for every code read from the scanner input. Codes are the
+// print codes of receipts (PRINT-42), completion URLs from QR codes, or ticket ref_ids.
+type Reader struct {
+	config   Config
+	database *db.Database
+
+	mu       sync.Mutex
+	lastSeen map[string]time.Time // Last scan time of each code, to ignore duplicates
+	input    io.Closer            // Input being read, closed to stop the reader
+	closed   bool
+}
+
+// New creates a reader that records completions in the database
+func New(config Config, database *db.Database) *Reader {
+	return &Reader{
+		config:   config,
+		database: database,
+		lastSeen: make(map[string]time.Time),
+	}
+}
+
+// Start reads the configured input in the background. Named pipes and devices are opened
+// again when their writer goes away, stdin and regular files are read once.
+func (r *Reader) Start() error {
+	if r.config.Input == StdinInput {
+		log.Printf("🔖 Reading scanned codes from stdin")
+		go r.readInput(os.Stdin, "stdin")
+		return nil
+	}
+
+	info, err := os.Stat(r.config.Input)
+	if err != nil {
+		return fmt.Errorf("failed to open scanner input: %v", err)
+	}
+	reopen := info.Mode()&(os.ModeNamedPipe|os.ModeDevice) != 0
+
+	log.Printf("🔖 Reading scanned codes from %s", r.config.Input)
+	go func() {
+		for {
+			// Opening a named pipe waits for a writer
+			file, err := os.Open(r.config.Input)
+			if err != nil {
+				log.Printf("❌ Failed to open scanner input %s: %v", r.config.Input, err)
+				return
+			}
+			r.readInput(file, r.config.Input)
+
+			if !reopen || r.isClosed() {
+				return
+			}
+			time.Sleep(reopenDelay)
+		}
+	}()
+	return nil
+}
+
+// Close stops reading the scanner input
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	if r.input != nil && r.input != os.Stdin {
+		return r.input.Close()
+	}
+	return nil
+}
+
+// isClosed reports whether Close was called
+func (r *Reader) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+// readInput reads an input until it ends and closes it
+func (r *Reader) readInput(input io.ReadCloser, name string) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		input.Close()
+		return
+	}
+	r.input = input
+	r.mu.Unlock()
+
+	if err := r.Read(input); err != nil && !r.isClosed() {
+		log.Printf("⚠️  Warning: Stopped reading scanner input %s: %v", name, err)
+	}
+	input.Close()
+}
+
+// Read records the codes of every line until the input ends, such as a pipe being closed
+func (r *Reader) Read(input io.Reader) error {
+	lines := bufio.NewScanner(input)
+	for lines.Scan() {
+		r.Scan(lines.Text())
+	}
+	return lines.Err()
+}
+
+// Scan records the completion of a single scanned code and logs the outcome.
+// It returns the completion, nil when the code was empty, a duplicate or unknown.
+func (r *Reader) Scan(code string) *db.Completion {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	last, seen := r.lastSeen[code]
+	r.lastSeen[code] = now
+	for c, t := range r.lastSeen {
+		if now.Sub(t) > r.config.DuplicateWindow {
+			delete(r.lastSeen, c)
+		}
+	}
+	r.mu.Unlock()
+
+	if seen && now.Sub(last) <= r.config.DuplicateWindow {
+		log.Printf("🔁 Ignoring duplicate scan %q", code)
+		return nil
+	}
+
+	completion, err := tickets.CompleteCode(r.database, code, db.CompletionSourceScan)
+	switch {
+	case errors.Is(err, tickets.ErrAlreadyCompleted):
+		log.Printf("🔁 Scan %q: the print was already completed", code)
+		return nil
+	case err != nil:
+		log.Printf("⚠️  Warning: Unknown scan %q: %v", code, err)
+		return nil
+	}
+
+	if completion.PrintID != 0 {
+		log.Printf("✅ Scan %q: completed ticket %d (print %d)", code, completion.TicketID, completion.PrintID)
+	} else {
+		log.Printf("✅ Scan %q: completed ticket %d", code, completion.TicketID)
+	}
+	return completion
+}
@@ -0,0 +1,113 @@
+package scanner
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"printy/internal/db"
+	"printy/internal/tickets"
+)
+
+// newTestDatabase opens a migrated database with a printed ticket and a ticket that was
+// never printed
+func newTestDatabase(t *testing.T) (*db.Database, *db.Print, *db.Ticket) {
+	database, err := db.New(filepath.Join(t.TempDir(), "printy.db"))
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	now := time.Now()
+	printed := &db.Ticket{RefID: "printed", Title: "Water the plants", CreatedAt: now, UpdatedAt: now}
+	unprinted := &db.Ticket{RefID: "unprinted", Title: "Take out the trash", CreatedAt: now, UpdatedAt: now}
+	for _, ticket := range []*db.Ticket{printed, unprinted} {
+		if err := database.CreateTicket(ticket); err != nil {
+			t.Fatalf("CreateTicket: %v", err)
+		}
+	}
+
+	print := &db.Print{TicketID: printed.ID, Printer: "test", CreatedAt: now, UpdatedAt: now}
+	if err := database.CreatePrint(print); err != nil {
+		t.Fatalf("CreatePrint: %v", err)
+	}
+	return database, print, unprinted
+}
+
+// completions returns the completions of a ticket
+func completions(t *testing.T, database *db.Database, ticketID int) []db.Completion {
+	completions, err := database.GetCompletionsByTicketID(ticketID)
+	if err != nil {
+		t.Fatalf("GetCompletionsByTicketID: %v", err)
+	}
+	return completions
+}
+
+func TestReadPipe(t *testing.T) {
+	database, print, unprinted := newTestDatabase(t)
+	reader := New(Config{DuplicateWindow: time.Minute}, database)
+
+	input, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- reader.Read(input) }()
+
+	for _, line := range []string{
+		tickets.PrintCode(print.ID), // Valid print code
+		"PRINT-999",                 // Unknown print
+		"no-such-ticket",            // Unknown ref_id
+		"",                          // Empty line
+		unprinted.RefID,             // Ticket without prints
+		unprinted.RefID,             // Duplicate within the window
+	} {
+		if _, err := io.WriteString(writer, line+"\r\n"); err != nil {
+			t.Fatalf("write %q: %v", line, err)
+		}
+	}
+
+	// The end of the input stops the reader without an error
+	writer.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read did not return at the end of the input")
+	}
+
+	got := completions(t, database, print.TicketID)
+	if len(got) != 1 || got[0].PrintID != print.ID || got[0].Source != db.CompletionSourceScan {
+		t.Errorf("completions of the printed ticket = %+v, want one scan of print %d", got, print.ID)
+	}
+	if got := completions(t, database, unprinted.ID); len(got) != 1 {
+		t.Errorf("ticket scanned twice within the window has %d completions, want 1", len(got))
+	}
+}
+
+func TestScanAfterDuplicateWindow(t *testing.T) {
+	database, print, _ := newTestDatabase(t)
+	reader := New(Config{DuplicateWindow: 0}, database)
+
+	code := tickets.PrintCode(print.ID)
+	if completion := reader.Scan(code); completion == nil {
+		t.Fatalf("Scan(%q) did not complete the print", code)
+	}
+
+	// A receipt scanned again later does not complete its ticket again, by print code
+	// or by reference ID
+	if completion := reader.Scan(code); completion != nil {
+		t.Errorf("Scan(%q) again = %+v, want the print to be already completed", code, completion)
+	}
+	ticket, err := database.GetTicketByID(print.TicketID)
+	if err != nil {
+		t.Fatalf("GetTicketByID: %v", err)
+	}
+	if completion := reader.Scan(ticket.RefID); completion != nil {
+		t.Errorf("Scan(%q) = %+v, want the last print to be already completed", ticket.RefID, completion)
+	}
+
+	if got := completions(t, database, print.TicketID); len(got) != 1 {
+		t.Errorf("ticket has %d completions, want 1", len(got))
+	}
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+
+	"printy/internal/db"
+	"printy/internal/tickets"
+)
+
+// CompleteRequest identifies the ticket to mark as done, with exactly one of its fields
+type CompleteRequest struct {
+	TicketID int    `json:"ticket_id,omitempty"`
+	PrintID  int    `json:"print_id,omitempty"`
+	Code     string `json:"code,omitempty"` // Scanned code: print code (PRINT-42), completion URL or ticket ref_id
+}
+
+// CompletionResponse represents the response for completion requests
+type CompletionResponse struct {
+	Success    bool           `json:"success"`
+	Message    string         `json:"message"`
+	Completion *db.Completion `json:"completion,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// handleComplete marks a ticket as done by ticket ID, print ID or scanned code
+func (s *Server) handleComplete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var completeReq CompleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&completeReq); err != nil {
+		response := CompletionResponse{
+			Success: false,
+			Message: "Invalid JSON body",
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	set := 0
+	for _, ok := range []bool{completeReq.TicketID != 0, completeReq.PrintID != 0, completeReq.Code != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		response := CompletionResponse{
+			Success: false,
+			Message: "Invalid completion request",
+			Error:   "exactly one of ticket_id, print_id or code is required",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	var completion *db.Completion
+	var err error
+	switch {
+	case completeReq.TicketID != 0:
+		completion, err = tickets.CompleteTicket(s.database, completeReq.TicketID, db.CompletionSourceAPI)
+	case completeReq.PrintID != 0:
+		completion, err = tickets.CompletePrint(s.database, completeReq.PrintID, db.CompletionSourceAPI)
+	default:
+		completion, err = tickets.CompleteCode(s.database, completeReq.Code, db.CompletionSourceAPI)
+	}
+	s.writeCompletion(w, completion, err)
+}
+
+// completeLinkPage is the page shown when a completion URL is opened in a browser
+var completeLinkPage = template.Must(template.New("complete").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Heading}}</title>
+</head>
+<body>
+<h1>{{.Heading}}</h1>
+{{if .Ticket}}<p><strong>{{.Ticket.Title}}</strong>{{if .Ticket.Assignee}} ({{.Ticket.Assignee}}){{end}}</p>{{end}}
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+{{if .Confirm}}<form method="post"><button type="submit">Mark as done</button></form>{{end}}
+</body>
+</html>
+`))
+
+// completeLinkData fills completeLinkPage
+type completeLinkData struct {
+	Heading string
+	Message string
+	Ticket  *db.Ticket
+	Confirm bool // Show the form that completes the ticket
+}
+
+// handleCompleteLink serves the completion URL of a ticket, opened by scanning the QR code
+// of a printed ticket. GET shows a confirmation page and only POST marks the ticket as done,
+// so link previews and prefetches do not complete tickets.
+func (s *Server) handleCompleteLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The confirmation form posts back to the link and expects a page in return
+	page := r.Method == http.MethodGet || r.Header.Get("Content-Type") == "application/x-www-form-urlencoded"
+
+	ticket, err := s.database.GetTicketByRefID(r.PathValue("ref_id"))
+	if err != nil {
+		if page {
+			status, message := completionError(err)
+			writeCompleteLinkPage(w, status, completeLinkData{Heading: message, Message: err.Error()})
+			return
+		}
+		s.writeCompletion(w, nil, err)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		writeCompleteLinkPage(w, http.StatusOK, completeLinkData{Heading: "Complete ticket?", Ticket: ticket, Confirm: true})
+		return
+	}
+
+	completion, err := tickets.CompleteTicket(s.database, ticket.ID, db.CompletionSourceLink)
+	if !page {
+		s.writeCompletion(w, completion, err)
+		return
+	}
+	if err != nil {
+		status, message := completionError(err)
+		writeCompleteLinkPage(w, status, completeLinkData{Heading: message, Message: err.Error(), Ticket: ticket})
+		return
+	}
+	log.Printf("✅ Ticket %d completed (%s)", completion.TicketID, completion.Source)
+	writeCompleteLinkPage(w, http.StatusCreated, completeLinkData{Heading: "Ticket completed", Ticket: ticket})
+}
+
+// writeCompleteLinkPage writes the HTML page of a completion URL
+func writeCompleteLinkPage(w http.ResponseWriter, status int, data completeLinkData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := completeLinkPage.Execute(w, data); err != nil {
+		log.Printf("⚠️ Failed to write completion page: %v", err)
+	}
+}
+
+// completionError returns the HTTP status and message of a failed completion
+func completionError(err error) (int, string) {
+	switch {
+	case errors.Is(err, tickets.ErrAlreadyCompleted):
+		return http.StatusConflict, "Print already completed"
+	case errors.Is(err, db.ErrTicketNotFound) || errors.Is(err, db.ErrPrintNotFound):
+		return http.StatusNotFound, "Not found"
+	default:
+		return http.StatusInternalServerError, "Failed to complete ticket"
+	}
+}
+
+// writeCompletion writes the outcome of a completion
+func (s *Server) writeCompletion(w http.ResponseWriter, completion *db.Completion, err error) {
+	if err != nil {
+		status, message := completionError(err)
+		response := CompletionResponse{
+			Success: false,
+			Message: message,
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	log.Printf("✅ Ticket %d completed (%s)", completion.TicketID, completion.Source)
+	response := CompletionResponse{
+		Success:    true,
+		Message:    fmt.Sprintf("Completed ticket %d", completion.TicketID),
+		Completion: completion,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"printy/internal/db"
+)
+
+// sseKeepAlive is how often a comment is sent to keep idle event streams open
+const sseKeepAlive = 15 * time.Second
+
+// JobStatusResponse represents the response for job status requests
+type JobStatusResponse struct {
+	Success bool          `json:"success"`
+	Job     *db.Job       `json:"job,omitempty"`
+	Events  []db.JobEvent `json:"events,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// handleGetJob returns a job with every stage it went through
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	job, ok := s.lookupJob(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := s.database.GetJobEvents(job.ID)
+	if err != nil {
+		response := JobStatusResponse{
+			Success: false,
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	response := JobStatusResponse{
+		Success: true,
+		Job:     job,
+		Events:  events,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleJobEvents streams the events of a job as Server-Sent Events until it finishes
+func (s *Server) handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	job, ok := s.lookupJob(w, r)
+	if !ok {
+		return
+	}
+
+	// Subscribe before reading the history so no event falls in between
+	live, unsubscribe := s.events.Subscribe(job.ID)
+	defer unsubscribe()
+
+	history, err := s.database.GetJobEvents(job.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	controller := http.NewResponseController(w)
+	controller.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// Events are sent once, the history and live events overlap
+	sent := make(map[int]bool)
+	for _, event := range history {
+		if err := writeSSE(w, event); err != nil {
+			return
+		}
+		sent[event.ID] = true
+		if event.IsFinal() {
+			controller.Flush()
+			return
+		}
+	}
+	controller.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			// Live events are dropped when the stream falls behind, the database has them all
+			done, err := s.replayJobEvents(w, job.ID, sent)
+			if err != nil || done {
+				controller.Flush()
+				return
+			}
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			controller.Flush()
+		case event := <-live:
+			if sent[event.ID] {
+				continue // Already sent from the history
+			}
+			if err := writeSSE(w, event); err != nil {
+				return
+			}
+			sent[event.ID] = true
+			controller.Flush()
+			if event.IsFinal() {
+				return
+			}
+		}
+	}
+}
+
+// replayJobEvents writes the stored events of a job that were not sent yet. It reports true
+// once the job is finished, which also ends streams whose final event was never recorded.
+func (s *Server) replayJobEvents(w http.ResponseWriter, jobID int, sent map[int]bool) (bool, error) {
+	job, err := s.database.GetJobByID(jobID)
+	if err != nil {
+		return false, err
+	}
+	events, err := s.database.GetJobEvents(jobID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, event := range events {
+		if sent[event.ID] {
+			continue
+		}
+		if err := writeSSE(w, event); err != nil {
+			return false, err
+		}
+		sent[event.ID] = true
+		if event.IsFinal() {
+			return true, nil
+		}
+	}
+	return job.IsFinished(), nil
+}
+
+// lookupJob loads the job named by the {id} path segment, writing an error response on failure
+func (s *Server) lookupJob(w http.ResponseWriter, r *http.Request) (*db.Job, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response := JobStatusResponse{
+			Success: false,
+			Error:   fmt.Sprintf("invalid job id: %q", r.PathValue("id")),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return nil, false
+	}
+
+	job, err := s.database.GetJobByID(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, db.ErrJobNotFound) {
+			status = http.StatusNotFound
+		}
+		response := JobStatusResponse{
+			Success: false,
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+		return nil, false
+	}
+
+	return job, true
+}
+
+// writeSSE writes a single job event in Server-Sent Events format
+func writeSSE(w http.ResponseWriter, event db.JobEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Stage, data)
+	return err
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"printy/internal/db"
+	"printy/internal/printer"
+	"printy/internal/tickets"
+)
+
+// Preview stages that can be returned
+const (
+	PreviewStagePNG = "png" // Final image after rasterizing and dithering, the default
+	PreviewStageSVG = "svg" // Filled template before rasterizing
+)
+
+// PreviewRequest represents a preview request. It takes the fields of a print request,
+// or the ref_id of a stored ticket whose title, assignee and template are used unless set.
+type PreviewRequest struct {
+	RefID string `json:"ref_id,omitempty"`
+	PrintRequest
+}
+
+// handlePreview renders a ticket the way it would be printed and returns the image
+// instead of printing it. ?stage=svg returns the filled template instead.
+func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var previewReq PreviewRequest
+	var err error
+	if r.Method == http.MethodPost {
+		err = json.NewDecoder(r.Body).Decode(&previewReq)
+	} else {
+		previewReq, err = parsePreviewQuery(r.URL.Query())
+	}
+	if err != nil {
+		response := PrintResponse{
+			Success: false,
+			Message: "Invalid preview request",
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	stage := r.URL.Query().Get("stage")
+	if stage == "" {
+		stage = PreviewStagePNG
+	}
+	if stage != PreviewStagePNG && stage != PreviewStageSVG {
+		response := PrintResponse{
+			Success: false,
+			Message: "Invalid preview stage",
+			Error:   fmt.Sprintf("stage must be %s or %s, got %q", PreviewStagePNG, PreviewStageSVG, stage),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Start from the stored ticket, the request fields override it
+	ticket := db.Ticket{}
+	if previewReq.RefID != "" {
+		stored, err := s.database.GetTicketByRefID(previewReq.RefID)
+		if err != nil {
+			response := PrintResponse{
+				Success: false,
+				Message: "Ticket not found",
+				Error:   err.Error(),
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		ticket = *stored
+	}
+	if previewReq.Title != "" {
+		ticket.Title = previewReq.Title
+	}
+	if previewReq.Assignee != "" {
+		ticket.Assignee = previewReq.Assignee
+	}
+
+	template := previewReq.Template
+	if template == "" {
+		template = s.rules.Select(ticket)
+	}
+	if template != "" && !s.templates.Has(template) {
+		response := PrintResponse{
+			Success: false,
+			Message: "Unknown template",
+			Error:   fmt.Sprintf("template not found: %s", template),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// The printer sets the paper width and the default raster options
+	worker, err := s.printers.Worker(s.routePrinter(previewReq.Printer, ticket, template))
+	if err != nil {
+		response := PrintResponse{
+			Success: false,
+			Message: "Unknown printer",
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	p := worker.Printer()
+
+	if err := previewReq.RasterOptions.Validate(); err != nil {
+		response := PrintResponse{
+			Success: false,
+			Message: "Invalid raster options",
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if stage == PreviewStageSVG {
+		svgContent, err := p.RenderSVG(template, previewTicket(ticket))
+		if err != nil {
+			response := PrintResponse{
+				Success: false,
+				Message: "Failed to render preview",
+				Error:   err.Error(),
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(svgContent))
+		return
+	}
+
+	rendered, err := p.Render(template, previewTicket(ticket), previewReq.RasterOptions, nil, nil)
+	if err != nil {
+		response := PrintResponse{
+			Success: false,
+			Message: "Failed to render preview",
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	if err := png.Encode(w, p.Preview(rendered)); err != nil {
+		log.Printf("⚠️  Warning: Failed to encode preview: %v", err)
+	}
+}
+
+// parsePreviewQuery reads a preview request from query parameters
+func parsePreviewQuery(query url.Values) (PreviewRequest, error) {
+	previewReq := PreviewRequest{
+		RefID: query.Get("ref_id"),
+		PrintRequest: PrintRequest{
+			Title:    query.Get("title"),
+			Assignee: query.Get("assignee"),
+			Template: query.Get("template"),
+			Printer:  query.Get("printer"),
+		},
+	}
+	previewReq.Dither = query.Get("dither")
+
+	for name, value := range map[string]*float64{
+		"threshold": &previewReq.Threshold,
+		"gamma":     &previewReq.Gamma,
+		"contrast":  &previewReq.Contrast,
+	} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return PreviewRequest{}, fmt.Errorf("invalid %s %q", name, raw)
+		}
+		*value = v
+	}
+
+	return previewReq, nil
+}
+
+// previewTicket returns the template fields of a ticket, with the completion URL it prints with
+func previewTicket(ticket db.Ticket) printer.Ticket {
+	return printer.Ticket{
+		RefID:       ticket.RefID,
+		Title:       ticket.Title,
+		Assignee:    ticket.Assignee,
+		URL:         ticket.URL,
+		CompleteURL: tickets.CompletionURL(ticket.RefID),
+	}
+}
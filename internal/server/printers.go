@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"printy/internal/db"
+	"printy/internal/printer"
+)
+
+// PrinterStatusResponse represents the response for printer status requests
+type PrinterStatusResponse struct {
+	Success    bool            `json:"success"`
+	Printer    string          `json:"printer"`
+	Profile    string          `json:"profile"`
+	Ready      bool            `json:"ready"`
+	Problems   []string        `json:"problems,omitempty"`
+	Status     *printer.Status `json:"status,omitempty"`
+	QueuedJobs int             `json:"queued_jobs"`
+	HeldJobs   int             `json:"held_jobs"` // Jobs waiting for the printer to recover
+	Error      string          `json:"error,omitempty"`
+}
+
+// handlePrinterStatus asks a printer for its real-time status
+func (s *Server) handlePrinterStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.PathValue("name")
+	worker, err := s.printers.Worker(name)
+	if err != nil || name == "" {
+		response := PrinterStatusResponse{
+			Success: false,
+			Printer: name,
+			Error:   fmt.Sprintf("printer not found: %s", name),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	p := worker.Printer()
+	response := PrinterStatusResponse{
+		Success: true,
+		Printer: name,
+		Profile: p.Profile().Name,
+	}
+
+	for status, count := range map[string]*int{
+		db.JobStatusQueued: &response.QueuedJobs,
+		db.JobStatusHeld:   &response.HeldJobs,
+	} {
+		if *count, err = s.database.CountJobs(name, status); err != nil {
+			response.Success = false
+			response.Error = err.Error()
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+	}
+
+	status, err := p.Status()
+	response.Status = &status
+	if err != nil {
+		// An unreachable printer is a valid answer, it is reported as offline
+		response.Ready = false
+		response.Problems = []string{"not responding"}
+		response.Error = err.Error()
+	} else {
+		response.Ready = status.Ready()
+		response.Problems = status.Problems()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
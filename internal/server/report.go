@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"printy/internal/db"
+	"printy/internal/printer"
+)
+
+// PrintReportRequest represents a request to print the weekly report
+type PrintReportRequest struct {
+	Assignee string `json:"assignee,omitempty"` // Limits the report to the tickets of an assignee
+	Template string `json:"template,omitempty"` // Report template, defaults to weekly
+	Printer  string `json:"printer,omitempty"`  // Defaults to the printer routing rules
+}
+
+// handlePrintReport queues a receipt with the print statistics of the last week.
+// The statistics are computed when the job is printed.
+func (s *Server) handlePrintReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse JSON request body (optional)
+	var reportReq PrintReportRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&reportReq); err != nil {
+			response := JobResponse{
+				Success: false,
+				Message: "Invalid JSON body",
+				Error:   err.Error(),
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+	}
+
+	template := reportReq.Template
+	if template == "" {
+		template = printer.DefaultReportTemplate
+	}
+	if tmpl, err := s.templates.Get(template); err != nil || tmpl.Kind != printer.TemplateKindReport {
+		response := JobResponse{
+			Success: false,
+			Message: "Unknown report template",
+			Error:   fmt.Sprintf("report template not found: %s", template),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if reportReq.Printer != "" && !s.printers.Has(reportReq.Printer) {
+		response := JobResponse{
+			Success: false,
+			Message: "Unknown printer",
+			Error:   fmt.Sprintf("printer not found: %s", reportReq.Printer),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	job := &db.Job{Kind: db.JobKindReport}
+	if err := job.SetPayload(db.JobPayload{
+		Assignee: reportReq.Assignee,
+		Template: template,
+	}); err != nil {
+		response := JobResponse{
+			Success: false,
+			Message: "Failed to encode report job",
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// The report is routed like a ticket of the requested assignee
+	printerName := s.routePrinter(reportReq.Printer, db.Ticket{Assignee: reportReq.Assignee}, template)
+	if err := s.printers.Enqueue(job, printerName); err != nil {
+		response := JobResponse{
+			Success: false,
+			Message: "Failed to queue report",
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Accepted response, the worker prints in the background
+	response := JobResponse{
+		Success: true,
+		Message: "Queued the weekly report",
+		JobID:   job.ID,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"printy/internal/db"
+)
+
+// ReprintRequest represents the options of a reprint, every field is optional
+type ReprintRequest struct {
+	Printer       string `json:"printer,omitempty"`        // Defaults to the printer of the original print
+	ResetCooldown bool   `json:"reset_cooldown,omitempty"` // Restart the cooldown of the ticket like a new print
+}
+
+// errNotReprintable is returned when a print did not keep what is needed to print it again
+var errNotReprintable = errors.New("print cannot be reprinted")
+
+// handleReprint queues a job that prints a recorded print again, such as a lost receipt
+func (s *Server) handleReprint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	printID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response := JobResponse{
+			Success: false,
+			Message: "Invalid print ID",
+			Error:   fmt.Sprintf("invalid print id: %q", r.PathValue("id")),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Parse JSON request body, an empty body keeps the defaults
+	var reprintReq ReprintRequest
+	if err := json.NewDecoder(r.Body).Decode(&reprintReq); err != nil && !errors.Is(err, io.EOF) {
+		response := JobResponse{
+			Success: false,
+			Message: "Invalid JSON body",
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if reprintReq.Printer != "" && !s.printers.Has(reprintReq.Printer) {
+		response := JobResponse{
+			Success: false,
+			Message: "Unknown printer",
+			Error:   fmt.Sprintf("printer not found: %s", reprintReq.Printer),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	original, err := s.database.GetPrintByID(printID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, db.ErrPrintNotFound) {
+			status = http.StatusNotFound
+		}
+		response := JobResponse{
+			Success: false,
+			Message: "Failed to get print",
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	job, printerName, err := s.reprintJob(original, reprintReq)
+	if err == nil {
+		err = s.printers.Enqueue(job, printerName)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errNotReprintable) {
+			status = http.StatusConflict
+		}
+		response := JobResponse{
+			Success: false,
+			Message: "Failed to queue reprint",
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	log.Printf("🔁 Queued job %d to reprint print %d on %s", job.ID, original.ID, job.Printer)
+
+	// Accepted response, the worker prints in the background
+	response := JobResponse{
+		Success: true,
+		Message: fmt.Sprintf("Queued a reprint of print %d", original.ID),
+		JobID:   job.ID,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// reprintJob builds the job that prints a print again and picks its printer. Prints are
+// rendered again from what they recorded, so a reprinted ticket carries the code of its own
+// print record. A ticket printed in an agenda is reprinted on its own with its ticket template.
+// Raw data, and the ESC/POS data of prints without a ticket when it was kept, is sent again.
+func (s *Server) reprintJob(original *db.Print, reprintReq ReprintRequest) (*db.Job, string, error) {
+	if original.Status != db.PrintStatusSucceeded {
+		return nil, "", fmt.Errorf("%w: print %d is %s", errNotReprintable, original.ID, original.Status)
+	}
+
+	// The original job keeps the raster options, the assignee of a report and raw data
+	var payload db.JobPayload
+	if original.JobID != 0 {
+		originalJob, err := s.database.GetJobByID(original.JobID)
+		switch {
+		case err == nil:
+			if payload, err = originalJob.GetPayload(); err != nil {
+				return nil, "", fmt.Errorf("invalid payload of job %d: %v", original.JobID, err)
+			}
+		case !errors.Is(err, db.ErrJobNotFound):
+			return nil, "", err
+		}
+	}
+	payload.Template = original.Template
+	payload.ReprintOf = original.ID
+	payload.ResetCooldown = reprintReq.ResetCooldown
+
+	job := &db.Job{Kind: original.Kind}
+	var ticket db.Ticket
+	switch original.Kind {
+	case db.JobKindRaw:
+		if len(payload.Data) == 0 {
+			return nil, "", fmt.Errorf("%w: the data of print %d is no longer stored", errNotReprintable, original.ID)
+		}
+	case db.JobKindReport:
+		// Rendered again for the assignee of the original report, with the current statistics
+		ticket.Assignee = payload.Assignee
+	default:
+		// The other tickets of an agenda are left out
+		job.Kind = db.JobKindTicket
+		payload.Tickets = nil
+		if original.Kind == db.JobKindAgenda {
+			payload.Template = ""
+		}
+
+		if original.TicketID != 0 {
+			printed, err := s.database.GetTicketByID(original.TicketID)
+			if err != nil {
+				return nil, "", err
+			}
+			ticket = *printed
+			job.TicketID = ticket.ID
+		}
+
+		payload.RefID = ticket.RefID
+		payload.URL = ticket.URL
+		payload.Title = original.Title
+		payload.Assignee = original.Assignee
+		if payload.Title == "" {
+			// Printed before print records kept the fields, the ticket is the best guess
+			payload.Title = ticket.Title
+			payload.Assignee = ticket.Assignee
+		}
+		if payload.Title == "" {
+			return nil, "", fmt.Errorf("%w: print %d did not record what was printed", errNotReprintable, original.ID)
+		}
+		if original.TicketID == 0 {
+			// Routed like a ticket of the printed assignee
+			ticket.Assignee = payload.Assignee
+		}
+		if payload.Template == "" {
+			payload.Template = s.rules.Select(ticket)
+		}
+	}
+
+	printerName := reprintReq.Printer
+	if printerName == "" && s.printers.Has(original.Printer) {
+		printerName = original.Printer
+	}
+	if printerName == "" {
+		printerName = s.routePrinter("", ticket, payload.Template)
+	}
+
+	// Kept ESC/POS data is encoded for the printer profile it was sent to. It is only sent again
+	// for prints without a ticket: tickets carry the print code of the original record, reports
+	// the statistics of their time and agendas every ticket, so those are rendered again. Data
+	// in the payload of an original job that was itself a reprint is dropped too.
+	if job.Kind != db.JobKindRaw {
+		payload.Data = nil
+	}
+	if job.Kind == db.JobKindTicket && original.Kind == db.JobKindTicket && original.TicketID == 0 &&
+		original.JobID != 0 && printerName == original.Printer {
+		if worker, err := s.printers.Worker(printerName); err == nil {
+			if data, err := worker.Printer().Artifacts(original.JobID).ReadFile("job.escpos"); err == nil {
+				payload.Data = data
+			}
+		}
+	}
+
+	if err := job.SetPayload(payload); err != nil {
+		return nil, "", fmt.Errorf("failed to encode reprint job: %v", err)
+	}
+	return job, printerName, nil
+}
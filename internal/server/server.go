@@ -2,25 +2,38 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image/png"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"strconv"
 	"time"
 
 	"printy/internal/db"
+	"printy/internal/emulator"
 	"printy/internal/notion"
 	"printy/internal/printer"
+	"printy/internal/queue"
+	"printy/internal/rawprint"
+	"printy/internal/scanner"
 	"printy/internal/tickets"
 	"printy/internal/tmp"
 )
 
 // Server represents the HTTP server
 type Server struct {
-	printer  *printer.Printer
-	database *db.Database
-	port     string
+	templates *printer.Registry
+	database  *db.Database
+	emulators map[string]*emulator.Emulator // Printers whose transport is the emulator
+	printers  *queue.Pool
+	events    *queue.Broker
+	rules     tickets.TemplateRules
+	routes    tickets.PrinterRules
+	raw       *rawprint.Listener // Accepts raw ESC/POS jobs from other applications, nil when disabled
+	scanner   *scanner.Reader    // Completes the tickets read by a barcode scanner, nil when disabled
+	port      string
 }
 
 // PrintResponse represents the response for printing
@@ -30,6 +43,15 @@ type PrintResponse struct {
 	Error   string `json:"error,omitempty"`
 }
 
+// JobResponse represents the response for requests that enqueue print jobs
+type JobResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	JobID   int    `json:"job_id,omitempty"`
+	JobIDs  []int  `json:"job_ids,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
 // SyncTicketsResponse represents the response for syncing tickets
 type SyncTicketsResponse struct {
 	Success bool   `json:"success"`
@@ -40,41 +62,182 @@ type SyncTicketsResponse struct {
 
 // New creates a new HTTP server
 func New(port string) (*Server, error) {
-	printerName := os.Getenv("PRINTER_NAME")
+	configs, err := printer.LoadPrinterConfigs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load printers: %v", err)
+	}
 
-	// Initialize printer
-	p, err := printer.New(printerName)
+	rasterizer, err := printer.NewRasterizer(os.Getenv("SVG_RENDERER"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize SVG renderer: %v", err)
+	}
+
+	// Every printer renders the same templates
+	templates, err := printer.LoadTemplates()
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize printer: %v", err)
 	}
 
-	execDir, err := os.Getwd()
+	dbPath, err := db.Path()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get current directory: %v", err)
+		return nil, err
 	}
 
-	database, err := db.New(filepath.Join(execDir, "data", "printy.db"))
+	database, err := db.New(dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %v", err)
 	}
 
+	// Jobs that were interrupted by a restart are picked up again by the workers
+	requeued, err := database.RequeueInterruptedJobs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover print queue: %v", err)
+	}
+	if requeued > 0 {
+		log.Printf("♻️  Requeued %d interrupted print jobs", requeued)
+	}
+	interrupted, err := database.FailInterruptedPrints()
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover print records: %v", err)
+	}
+	if interrupted > 0 {
+		log.Printf("♻️  Marked %d interrupted prints as failed", interrupted)
+	}
+
+	events := queue.NewBroker()
+	printers := queue.NewPool()
+	emulators := make(map[string]*emulator.Emulator)
+
+	for _, config := range configs {
+		transport, err := printer.NewTransport(config.Transport, config.Address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize transport of printer %s: %v", config.Name, err)
+		}
+
+		profile, err := printer.LoadProfile(config.Profile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load profile of printer %s: %v", config.Name, err)
+		}
+
+		p, err := printer.New(config.Name, transport, rasterizer, profile, templates)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize printer %s: %v", config.Name, err)
+		}
+
+		// Keep a handle on emulators so their virtual receipts can be served
+		if em, ok := transport.(*emulator.Emulator); ok {
+			em.SetPaperWidth(profile.WidthDots)
+			emulators[config.Name] = em
+		}
+
+		if err := printers.Add(queue.NewWorker(database, p, events)); err != nil {
+			return nil, err
+		}
+		log.Printf("🖨️  Registered printer %q (%s, profile %s: %d dots, %.0fmm at %d dpi)",
+			config.Name, transportName(config.Transport), profile.Name, profile.WidthDots, profile.WidthMM(), profile.DPI)
+	}
+
+	for _, config := range configs {
+		if config.Fallback == "" {
+			continue
+		}
+		if err := printers.SetFallback(config.Name, config.Fallback); err != nil {
+			return nil, err
+		}
+		log.Printf("🔀 Jobs that fail on %q go to %q", config.Name, config.Fallback)
+	}
+
+	routes := tickets.LoadPrinterRules()
+	for _, name := range routes.Printers() {
+		if !printers.Has(name) {
+			return nil, fmt.Errorf("printer routing rules name unknown printer %s", name)
+		}
+	}
+
+	rawConfig, err := rawprint.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load raw listener configuration: %v", err)
+	}
+	var raw *rawprint.Listener
+	if rawConfig.Address != "" {
+		raw, err = rawprint.New(rawConfig, printers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scannerConfig, err := scanner.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scanner configuration: %v", err)
+	}
+	var reader *scanner.Reader
+	if scannerConfig.Input != "" {
+		reader = scanner.New(scannerConfig, database)
+	}
+
 	return &Server{
-		printer:  p,
-		database: database,
-		port:     port,
+		templates: templates,
+		database:  database,
+		emulators: emulators,
+		printers:  printers,
+		events:    events,
+		rules:     tickets.LoadTemplateRules(),
+		routes:    routes,
+		raw:       raw,
+		scanner:   reader,
+		port:      port,
 	}, nil
 }
 
-// Close closes the database connection
+// transportName returns the transport type shown in logs, empty meaning CUPS
+func transportName(transportType string) string {
+	if transportType == "" {
+		return printer.TransportCUPS
+	}
+	return transportType
+}
+
+// routePrinter returns the printer a job is sent to: the requested one, or the one picked
+// by the routing rules. An empty name is the default printer.
+func (s *Server) routePrinter(requested string, ticket db.Ticket, template string) string {
+	if requested != "" {
+		return requested
+	}
+	return s.routes.Select(ticket, template)
+}
+
+// Close stops the raw listener, the scanner and the print workers and closes the database connection
 func (s *Server) Close() error {
+	if s.raw != nil {
+		s.raw.Close()
+	}
+	if s.scanner != nil {
+		s.scanner.Close()
+	}
+	if s.printers != nil {
+		s.printers.Stop()
+	}
 	if s.database != nil {
 		return s.database.Close()
 	}
 	return nil
 }
 
-// Start starts the HTTP server
+// Start starts the print workers, the raw listener, the scanner and the HTTP server
 func (s *Server) Start() error {
+	s.printers.Start()
+
+	if s.raw != nil {
+		if err := s.raw.Start(); err != nil {
+			return err
+		}
+	}
+	if s.scanner != nil {
+		if err := s.scanner.Start(); err != nil {
+			return err
+		}
+	}
+
 	mux := http.NewServeMux()
 
 	// Register handlers
@@ -82,6 +245,8 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/print/", s.handlePrint) // Handle trailing slash
 	mux.HandleFunc("/print-backlog", s.handlePrintBacklog)
 	mux.HandleFunc("/print-backlog/", s.handlePrintBacklog) // Handle trailing slash
+	mux.HandleFunc("/print-report", s.handlePrintReport)
+	mux.HandleFunc("/print-report/", s.handlePrintReport) // Handle trailing slash
 	mux.HandleFunc("/sync-tickets", s.handleSyncTickets)
 	mux.HandleFunc("/sync-tickets/", s.handleSyncTickets) // Handle trailing slash
 	mux.HandleFunc("/clear-prints", s.handleClearPrints)
@@ -90,6 +255,21 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/clear-tickets/", s.handleClearTickets) // Handle trailing slash
 	mux.HandleFunc("/real-test", s.handleRealTest)
 	mux.HandleFunc("/real-test/", s.handleRealTest) // Handle trailing slash
+	mux.HandleFunc("/emulator/receipt", s.handleEmulatorReceipt)
+	mux.HandleFunc("/emulator/receipt/", s.handleEmulatorReceipt) // Handle trailing slash
+	mux.HandleFunc("/emulator/status", s.handleEmulatorStatus)
+	mux.HandleFunc("/emulator/status/", s.handleEmulatorStatus) // Handle trailing slash
+	mux.HandleFunc("/preview", s.handlePreview)
+	mux.HandleFunc("/preview/", s.handlePreview) // Handle trailing slash
+	mux.HandleFunc("/templates", s.handleTemplates)
+	mux.HandleFunc("/templates/", s.handleTemplates) // Handle trailing slash
+	mux.HandleFunc("/jobs/{id}", s.handleGetJob)
+	mux.HandleFunc("/jobs/{id}/events", s.handleJobEvents)
+	mux.HandleFunc("/printers/{name}/status", s.handlePrinterStatus)
+	mux.HandleFunc("/complete", s.handleComplete)
+	mux.HandleFunc("/complete/", s.handleComplete) // Handle trailing slash
+	mux.HandleFunc("/tickets/{ref_id}/complete", s.handleCompleteLink)
+	mux.HandleFunc("/prints/{id}/reprint", s.handleReprint)
 
 	server := &http.Server{
 		Addr:         ":" + s.port,
@@ -106,6 +286,11 @@ func (s *Server) Start() error {
 type PrintBacklogRequest struct {
 	Assignee string `json:"assignee,omitempty"`
 	Count    int    `json:"count,omitempty"`
+	Printer  string `json:"printer,omitempty"` // Defaults to the printer routing rules
+
+	// Agenda prints every ticket on one receipt instead of one receipt per ticket
+	Agenda   bool   `json:"agenda,omitempty"`
+	Template string `json:"template,omitempty"` // List template of the agenda, defaults to agenda
 }
 
 // handlePrintBacklog handles print backlog requests
@@ -131,6 +316,18 @@ func (s *Server) handlePrintBacklog(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if printReq.Printer != "" && !s.printers.Has(printReq.Printer) {
+		response := JobResponse{
+			Success: false,
+			Message: "Unknown printer",
+			Error:   fmt.Sprintf("printer not found: %s", printReq.Printer),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	// Set default count if not provided
 	count := printReq.Count
 	if count <= 0 {
@@ -151,39 +348,128 @@ func (s *Server) handlePrintBacklog(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Print each relevant ticket
-	for i, ticket := range relevantTickets {
-		startTime := time.Now()
-		log.Printf("🖨️  Starting print job %d/%d for ticket %s", i+1, len(relevantTickets), ticket.RefID)
+	if printReq.Agenda {
+		s.enqueueAgenda(w, printReq, relevantTickets)
+		return
+	}
+
+	// Enqueue a print job for each relevant ticket
+	var jobIDs []int
+	for _, ticket := range relevantTickets {
+		template := s.rules.Select(ticket)
+		job := &db.Job{
+			Kind:     db.JobKindTicket,
+			TicketID: ticket.ID,
+		}
+		if err := job.SetPayload(db.JobPayload{
+			RefID:    ticket.RefID,
+			Title:    ticket.Title,
+			Assignee: ticket.Assignee,
+			Template: template,
+			URL:      ticket.URL,
+		}); err != nil {
+			log.Printf("Failed to encode job for ticket %d: %v", ticket.ID, err)
+			continue
+		}
 
-		if err := s.printer.Print(ticket.RefID, ticket.Title, ticket.Assignee); err != nil {
-			log.Printf("Failed to print ticket %d: %v", ticket.ID, err)
+		if err := s.printers.Enqueue(job, s.routePrinter(printReq.Printer, ticket, template)); err != nil {
+			log.Printf("Failed to enqueue ticket %d: %v", ticket.ID, err)
 			continue
 		}
+		jobIDs = append(jobIDs, job.ID)
+	}
+
+	// Accepted response, the worker prints in the background
+	response := JobResponse{
+		Success: true,
+		Message: fmt.Sprintf("Queued print jobs for %d tickets", len(jobIDs)),
+		JobIDs:  jobIDs,
+	}
 
-		printDuration := time.Since(startTime)
-		log.Printf("✅ Print job %d completed in %v", i+1, printDuration)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// enqueueAgenda queues one job that prints the relevant tickets on a single receipt
+func (s *Server) enqueueAgenda(w http.ResponseWriter, printReq PrintBacklogRequest, relevantTickets []db.Ticket) {
+	template := printReq.Template
+	if template == "" {
+		template = printer.DefaultListTemplate
+	}
+	if tmpl, err := s.templates.Get(template); err != nil || tmpl.Kind != printer.TemplateKindList {
+		response := JobResponse{
+			Success: false,
+			Message: "Unknown list template",
+			Error:   fmt.Sprintf("list template not found: %s", template),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
 
-		// Create a print record in database
-		print := &db.Print{
-			TicketID:  ticket.ID,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+	if len(relevantTickets) == 0 {
+		response := JobResponse{
+			Success: true,
+			Message: "No tickets to print on the agenda",
 		}
-		if err := s.database.CreatePrint(print); err != nil {
-			log.Printf("⚠️  Warning: Failed to record print for ticket %d: %v", ticket.ID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	payload := db.JobPayload{
+		Assignee: printReq.Assignee,
+		Template: template,
+	}
+	for _, ticket := range relevantTickets {
+		payload.Tickets = append(payload.Tickets, db.JobTicket{
+			TicketID: ticket.ID,
+			RefID:    ticket.RefID,
+			Title:    ticket.Title,
+			Assignee: ticket.Assignee,
+			Priority: ticket.Priority,
+		})
+	}
+
+	job := &db.Job{Kind: db.JobKindAgenda}
+	if err := job.SetPayload(payload); err != nil {
+		response := JobResponse{
+			Success: false,
+			Message: "Failed to encode agenda job",
+			Error:   err.Error(),
 		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
 
+	// The agenda is routed like a ticket of the requested assignee
+	printerName := s.routePrinter(printReq.Printer, db.Ticket{Assignee: printReq.Assignee}, template)
+	if err := s.printers.Enqueue(job, printerName); err != nil {
+		response := JobResponse{
+			Success: false,
+			Message: "Failed to queue agenda",
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response)
+		return
 	}
 
-	// Success response
-	response := PrintResponse{
+	// Accepted response, the worker prints in the background
+	response := JobResponse{
 		Success: true,
-		Message: fmt.Sprintf("Print job completed successfully for %d tickets", len(relevantTickets)),
+		Message: fmt.Sprintf("Queued an agenda of %d tickets", len(relevantTickets)),
+		JobID:   job.ID,
+		JobIDs:  []int{job.ID},
 	}
-
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(response)
 }
 
@@ -191,6 +477,11 @@ func (s *Server) handlePrintBacklog(w http.ResponseWriter, r *http.Request) {
 type PrintRequest struct {
 	Title    string `json:"title"`
 	Assignee string `json:"assignee"`
+	Template string `json:"template,omitempty"` // Defaults to the template selection rules
+	Printer  string `json:"printer,omitempty"`  // Defaults to the printer routing rules
+
+	// Dither, threshold, gamma and contrast override the template options
+	printer.RasterOptions
 }
 
 // handlePrint handles direct print requests with JSON body
@@ -214,11 +505,66 @@ func (s *Server) handlePrint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Print with empty ref_id, title, and assignee from request
-	if err := s.printer.Print("", printReq.Title, printReq.Assignee); err != nil {
-		response := PrintResponse{
+	// Pick the template from the request or the selection rules
+	ticket := db.Ticket{Title: printReq.Title, Assignee: printReq.Assignee}
+	template := printReq.Template
+	if template == "" {
+		template = s.rules.Select(ticket)
+	}
+	if template != "" && !s.templates.Has(template) {
+		response := JobResponse{
+			Success: false,
+			Message: "Unknown template",
+			Error:   fmt.Sprintf("template not found: %s", template),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if printReq.Printer != "" && !s.printers.Has(printReq.Printer) {
+		response := JobResponse{
+			Success: false,
+			Message: "Unknown printer",
+			Error:   fmt.Sprintf("printer not found: %s", printReq.Printer),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if err := printReq.RasterOptions.Validate(); err != nil {
+		response := JobResponse{
 			Success: false,
-			Message: "Print job failed",
+			Message: "Invalid raster options",
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Enqueue with empty ref_id, title, and assignee from request
+	job := &db.Job{Kind: db.JobKindTicket}
+	err := job.SetPayload(db.JobPayload{
+		Title:     printReq.Title,
+		Assignee:  printReq.Assignee,
+		Template:  template,
+		Dither:    printReq.Dither,
+		Threshold: printReq.Threshold,
+		Gamma:     printReq.Gamma,
+		Contrast:  printReq.Contrast,
+	})
+	if err == nil {
+		err = s.printers.Enqueue(job, s.routePrinter(printReq.Printer, ticket, template))
+	}
+	if err != nil {
+		response := JobResponse{
+			Success: false,
+			Message: "Failed to queue print job",
 			Error:   err.Error(),
 		}
 		w.Header().Set("Content-Type", "application/json")
@@ -227,14 +573,15 @@ func (s *Server) handlePrint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Success response
-	response := PrintResponse{
+	// Accepted response, the worker prints in the background
+	response := JobResponse{
 		Success: true,
-		Message: "Print job completed successfully",
+		Message: "Print job queued",
+		JobID:   job.ID,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(response)
 }
 
@@ -282,10 +629,11 @@ func (s *Server) handleSyncTickets(w http.ResponseWriter, r *http.Request) {
 		priority := tickets.ParsePriority(notionTicket.Priority)
 		cooldown := tickets.ParseCooldown(notionTicket.Cooldown)
 		weekdays := notionTicket.Weekdays // Keep as JSON array string
+		cooldownFrom := tickets.ParseCooldownFrom(notionTicket.CooldownFrom)
 
 		// Check if ticket already exists
 		existingTicket, err := s.database.GetTicketByRefID(notionTicket.ID)
-		if err != nil && err.Error() != "ticket not found" {
+		if err != nil && !errors.Is(err, db.ErrTicketNotFound) {
 			log.Printf("Error checking existing ticket %s: %v", notionTicket.ID, err)
 			continue
 		}
@@ -298,6 +646,9 @@ func (s *Server) handleSyncTickets(w http.ResponseWriter, r *http.Request) {
 			existingTicket.Cooldown = cooldown
 			existingTicket.Weekdays = weekdays
 			existingTicket.Assignee = notionTicket.Assignee
+			existingTicket.Template = notionTicket.Template
+			existingTicket.URL = notionTicket.URL
+			existingTicket.CooldownFrom = cooldownFrom
 			existingTicket.UpdatedAt = now
 
 			if err := s.database.UpdateTicket(existingTicket); err != nil {
@@ -307,14 +658,17 @@ func (s *Server) handleSyncTickets(w http.ResponseWriter, r *http.Request) {
 		} else {
 			// Create new ticket
 			ticket := &db.Ticket{
-				RefID:     notionTicket.ID,
-				Title:     notionTicket.Name,
-				Priority:  priority,
-				Cooldown:  cooldown,
-				Weekdays:  weekdays,
-				Assignee:  notionTicket.Assignee,
-				CreatedAt: now,
-				UpdatedAt: now,
+				RefID:        notionTicket.ID,
+				Title:        notionTicket.Name,
+				Priority:     priority,
+				Cooldown:     cooldown,
+				Weekdays:     weekdays,
+				Assignee:     notionTicket.Assignee,
+				Template:     notionTicket.Template,
+				URL:          notionTicket.URL,
+				CooldownFrom: cooldownFrom,
+				CreatedAt:    now,
+				UpdatedAt:    now,
 			}
 
 			if err := s.database.CreateTicket(ticket); err != nil {
@@ -345,8 +699,12 @@ func (s *Server) handleClearPrints(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete all prints
-	if err := s.database.DeleteAllPrints(); err != nil {
+	// Delete all prints, and the completions that would keep tickets in cooldown
+	err := s.database.DeleteAllPrints()
+	if err == nil {
+		err = s.database.DeleteAllCompletions()
+	}
+	if err != nil {
 		response := PrintResponse{
 			Success: false,
 			Message: "Failed to clear prints",
@@ -410,7 +768,7 @@ func (s *Server) handleRealTest(w http.ResponseWriter, r *http.Request) {
 	log.Printf("🎬 Starting animation printing test...")
 
 	// Create animator instance
-	animator := tmp.NewAnimator(s.printer.GetPrinterName())
+	animator := tmp.NewAnimator(s.printers.Default().PrinterName())
 
 	// Print the animation
 	if err := animator.PrintAnimation(); err != nil {
@@ -439,3 +797,156 @@ func (s *Server) handleRealTest(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// handleEmulatorReceipt renders the last N jobs received by a printer emulator as a PNG
+func (s *Server) handleEmulatorReceipt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	em, _, ok := s.lookupEmulator(w, r)
+	if !ok {
+		return
+	}
+
+	// Number of jobs to show, defaults to the last one
+	count := 1
+	if n := r.URL.Query().Get("n"); n != "" {
+		parsed, err := strconv.Atoi(n)
+		if err != nil || parsed <= 0 {
+			response := PrintResponse{
+				Success: false,
+				Message: "Invalid job count",
+				Error:   fmt.Sprintf("n must be a positive integer, got %q", n),
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		count = parsed
+	}
+
+	receipt, err := em.Receipt(count)
+	if err != nil {
+		response := PrintResponse{
+			Success: false,
+			Message: "Failed to render virtual receipt",
+			Error:   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	if err := png.Encode(w, receipt); err != nil {
+		log.Printf("⚠️  Warning: Failed to encode virtual receipt: %v", err)
+	}
+}
+
+// EmulatorStatusResponse represents the response for the simulated emulator status
+type EmulatorStatusResponse struct {
+	Success bool            `json:"success"`
+	Status  emulator.Status `json:"status"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// handleEmulatorStatus returns the simulated status of an emulator, or changes it on POST
+// so paper out and cover open can be tried without a printer
+func (s *Server) handleEmulatorStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	em, printerName, ok := s.lookupEmulator(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var status emulator.Status
+		if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+			response := EmulatorStatusResponse{
+				Success: false,
+				Status:  em.Status(),
+				Error:   err.Error(),
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		em.SetStatus(status)
+
+		// Let the worker notice a recovered printer without waiting for its next poll
+		if worker, err := s.printers.Worker(printerName); err == nil {
+			worker.Notify()
+		}
+	}
+
+	response := EmulatorStatusResponse{
+		Success: true,
+		Status:  em.Status(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// lookupEmulator returns the emulator and printer name of the ?printer= query parameter, defaulting
+// to the first printer with an emulator, writing an error response when there is none
+func (s *Server) lookupEmulator(w http.ResponseWriter, r *http.Request) (*emulator.Emulator, string, bool) {
+	printerName := r.URL.Query().Get("printer")
+	if printerName == "" {
+		for _, worker := range s.printers.Workers() {
+			if _, ok := s.emulators[worker.PrinterName()]; ok {
+				printerName = worker.PrinterName()
+				break
+			}
+		}
+	}
+
+	em, ok := s.emulators[printerName]
+	if !ok {
+		response := PrintResponse{
+			Success: false,
+			Message: "Emulator is not enabled",
+			Error:   "set the transport of a printer to emulator to capture print jobs",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(response)
+		return nil, "", false
+	}
+
+	return em, printerName, true
+}
+
+// TemplatesResponse represents the response for listing templates
+type TemplatesResponse struct {
+	Success   bool                   `json:"success"`
+	Templates []printer.TemplateInfo `json:"templates"`
+}
+
+// handleTemplates lists the templates loaded in the registry
+func (s *Server) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := TemplatesResponse{
+		Success:   true,
+		Templates: s.templates.List(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,168 @@
+package tickets
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"printy/internal/db"
+)
+
+// DefaultCompletionGrace is how long a printed ticket that was not done stays out of the
+// backlog when COMPLETION_GRACE is not set, for tickets whose cooldown starts at completion
+const DefaultCompletionGrace = 24 * time.Hour
+
+// printCodePrefix starts the codes that identify a print, such as PRINT-42
+const printCodePrefix = "PRINT-"
+
+// ErrAlreadyCompleted is returned when a print that was already done is completed again
+var ErrAlreadyCompleted = errors.New("print was already completed")
+
+// CompletionURL returns the printy URL that marks a ticket as done, printed as a QR code
+// on tickets. It is empty when PUBLIC_URL is not set or the ticket has no reference ID.
+func CompletionURL(refID string) string {
+	publicURL := strings.TrimRight(strings.TrimSpace(os.Getenv("PUBLIC_URL")), "/")
+	if publicURL == "" || refID == "" {
+		return ""
+	}
+	return publicURL + "/tickets/" + url.PathEscape(refID) + "/complete"
+}
+
+// CompletionGrace returns the grace period from COMPLETION_GRACE, such as 12h
+func CompletionGrace() time.Duration {
+	raw := strings.TrimSpace(os.Getenv("COMPLETION_GRACE"))
+	if raw == "" {
+		return DefaultCompletionGrace
+	}
+
+	grace, err := time.ParseDuration(raw)
+	if err != nil || grace < 0 {
+		log.Printf("⚠️  Warning: Invalid COMPLETION_GRACE %q, using %s", raw, DefaultCompletionGrace)
+		return DefaultCompletionGrace
+	}
+	return grace
+}
+
+// PrintCode returns the code that identifies a print, for barcodes printed on receipts
+func PrintCode(printID int) string {
+	return fmt.Sprintf("%s%d", printCodePrefix, printID)
+}
+
+// CompleteTicket records that a ticket was done. The completion is linked to the last
+// successful print of the ticket, and ErrAlreadyCompleted is returned when that print was
+// done already, such as a receipt scanned again after the duplicate window.
+func CompleteTicket(database *db.Database, ticketID int, source string) (*db.Completion, error) {
+	if _, err := database.GetTicketByID(ticketID); err != nil {
+		return nil, err
+	}
+
+	completion := &db.Completion{
+		TicketID:  ticketID,
+		Source:    source,
+		CreatedAt: time.Now(),
+	}
+
+	prints, err := database.GetPrintsByTicketID(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	for _, print := range prints {
+		if print.Status != db.PrintStatusSucceeded {
+			continue
+		}
+		_, err := database.GetCompletionByPrintID(print.ID)
+		switch {
+		case err == nil:
+			return nil, ErrAlreadyCompleted
+		case !errors.Is(err, db.ErrCompletionNotFound):
+			return nil, err
+		}
+		completion.PrintID = print.ID
+		break
+	}
+
+	if err := database.CreateCompletion(completion); err != nil {
+		return nil, err
+	}
+	return completion, nil
+}
+
+// CompletePrint records that the ticket of a print was done.
+// It returns ErrAlreadyCompleted when the print was completed before.
+func CompletePrint(database *db.Database, printID int, source string) (*db.Completion, error) {
+	print, err := database.GetPrintByID(printID)
+	if err != nil {
+		return nil, err
+	}
+	if print.TicketID == 0 {
+		return nil, fmt.Errorf("print %d is not a ticket", printID)
+	}
+	if print.Status != db.PrintStatusSucceeded {
+		return nil, fmt.Errorf("print %d is %s, nothing was printed to complete", printID, print.Status)
+	}
+
+	if _, err := database.GetCompletionByPrintID(printID); err == nil {
+		return nil, ErrAlreadyCompleted
+	} else if !errors.Is(err, db.ErrCompletionNotFound) {
+		return nil, err
+	}
+
+	completion := &db.Completion{
+		TicketID:  print.TicketID,
+		PrintID:   printID,
+		Source:    source,
+		CreatedAt: time.Now(),
+	}
+	if err := database.CreateCompletion(completion); err != nil {
+		return nil, err
+	}
+	return completion, nil
+}
+
+// CompleteCode records the completion identified by a scanned code: a print code such as
+// PRINT-42, a completion URL or the reference ID of a ticket
+func CompleteCode(database *db.Database, code, source string) (*db.Completion, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, fmt.Errorf("empty code")
+	}
+
+	if printID, ok := parsePrintCode(code); ok {
+		return CompletePrint(database, printID, source)
+	}
+
+	refID := code
+	if parsed, err := url.Parse(code); err == nil && parsed.Scheme != "" {
+		ref, ok := strings.CutPrefix(parsed.Path, "/tickets/")
+		ref, ok2 := strings.CutSuffix(ref, "/complete")
+		if !ok || !ok2 || ref == "" {
+			return nil, fmt.Errorf("not a completion URL: %s", code)
+		}
+		if refID, err = url.PathUnescape(ref); err != nil {
+			return nil, fmt.Errorf("invalid completion URL %s: %v", code, err)
+		}
+	}
+
+	ticket, err := database.GetTicketByRefID(refID)
+	if err != nil {
+		return nil, err
+	}
+	return CompleteTicket(database, ticket.ID, source)
+}
+
+// parsePrintCode returns the print ID of a code written by PrintCode
+func parsePrintCode(code string) (int, bool) {
+	if len(code) <= len(printCodePrefix) || !strings.EqualFold(code[:len(printCodePrefix)], printCodePrefix) {
+		return 0, false
+	}
+	printID, err := strconv.Atoi(code[len(printCodePrefix):])
+	if err != nil || printID <= 0 {
+		return 0, false
+	}
+	return printID, true
+}
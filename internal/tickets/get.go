@@ -17,6 +17,7 @@ func GetRelevantTickets(database *db.Database, count int, assigneeFilter string)
 
 	var relevantTickets []db.Ticket
 	today := time.Now().Weekday()
+	grace := CompletionGrace()
 
 	for _, ticket := range allTickets {
 		// Check if ticket is relevant for today
@@ -25,7 +26,7 @@ func GetRelevantTickets(database *db.Database, count int, assigneeFilter string)
 		}
 
 		// Check if ticket is in cooldown
-		inCooldown, err := database.IsTicketInCooldown(ticket.ID)
+		inCooldown, err := database.IsTicketInCooldown(ticket.ID, grace)
 		if err != nil {
 			continue // Skip if error checking cooldown
 		}
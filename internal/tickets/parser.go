@@ -3,6 +3,8 @@ package tickets
 import (
 	"strconv"
 	"strings"
+
+	"printy/internal/db"
 )
 
 // ParsePriority parses a priority string and returns an integer value
@@ -102,6 +104,17 @@ func ParseCooldown(cooldownStr string) int {
 	return cooldown
 }
 
+// ParseCooldownFrom parses what the cooldown of a ticket is measured from.
+// Supports "print" (the default) and "completion", also as "done", "completed" or "completado".
+func ParseCooldownFrom(cooldownFromStr string) string {
+	switch strings.ToLower(strings.TrimSpace(cooldownFromStr)) {
+	case "completion", "completed", "done", "completado", "completada":
+		return db.CooldownFromCompletion
+	default:
+		return db.CooldownFromPrint
+	}
+}
+
 // ParseCooldownToDays parses a cooldown string and returns the number of days
 // This is a convenience function for when you need days instead of seconds
 func ParseCooldownToDays(cooldownStr string) float64 {
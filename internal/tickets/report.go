@@ -0,0 +1,171 @@
+package tickets
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"printy/internal/db"
+	"printy/internal/printer"
+)
+
+const (
+	// ReportDays is the number of days covered by the weekly report, ending with the report day
+	ReportDays = 7
+
+	// overdueGrace is how long a ticket may stay past its cooldown before it counts as overdue.
+	// The backlog is printed once a day, so a ticket past its cooldown for a day was skipped.
+	overdueGrace = 24 * time.Hour
+
+	// Number of tickets listed in each section of the report
+	reportMostPrinted  = 3
+	reportNeverPrinted = 5
+	reportOverdue      = 5
+
+	// unassigned is the label of prints of tickets without an assignee
+	unassigned = "Unassigned"
+)
+
+// WeeklyReport builds the statistics of the prints of the last ReportDays days up to now.
+// A non-empty assignee limits the report to the tickets of that assignee (case-insensitive).
+func WeeklyReport(database *db.Database, now time.Time, assignee string) (printer.Report, error) {
+	allTickets, err := database.GetAllTickets()
+	if err != nil {
+		return printer.Report{}, fmt.Errorf("failed to get tickets: %v", err)
+	}
+
+	location := printer.TemplateLocation()
+	now = now.In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	from := today.AddDate(0, 0, -(ReportDays - 1))
+
+	prints, err := database.GetPrintsByDateRange(from, now)
+	if err != nil {
+		return printer.Report{}, fmt.Errorf("failed to get prints: %v", err)
+	}
+	// Never printed and overdue tickets depend on prints before the period
+	lastPrinted, err := database.GetLastPrintTimes()
+	if err != nil {
+		return printer.Report{}, fmt.Errorf("failed to get last print times: %v", err)
+	}
+
+	report := printer.Report{
+		From:     from,
+		To:       now,
+		Assignee: assignee,
+	}
+
+	ticketsByID := make(map[int]db.Ticket)
+	for _, ticket := range allTickets {
+		if assignee != "" && !strings.Contains(strings.ToLower(ticket.Assignee), strings.ToLower(assignee)) {
+			continue
+		}
+		ticketsByID[ticket.ID] = ticket
+	}
+
+	dayCounts := make([]int, ReportDays)
+	assigneeCounts := make(map[string]int)
+	ticketCounts := make(map[int]int)
+	for _, print := range prints {
+		if print.Status != db.PrintStatusSucceeded {
+			continue
+		}
+		ticket, ok := ticketsByID[print.TicketID]
+		if !ok {
+			// Raw prints and prints of other assignees
+			continue
+		}
+
+		createdAt := print.CreatedAt.In(location)
+		day := time.Date(createdAt.Year(), createdAt.Month(), createdAt.Day(), 0, 0, 0, 0, location)
+		dayCounts[int(day.Sub(from).Hours()/24+0.5)]++
+
+		name := ticket.Assignee
+		if name == "" {
+			name = unassigned
+		}
+		assigneeCounts[name]++
+		ticketCounts[ticket.ID]++
+		report.Total++
+	}
+
+	for i, count := range dayCounts {
+		report.Days = append(report.Days, printer.ReportCount{
+			Label: from.AddDate(0, 0, i).Format("Mon"),
+			Count: count,
+		})
+	}
+
+	for name, count := range assigneeCounts {
+		report.Assignees = append(report.Assignees, printer.ReportCount{Label: name, Count: count})
+	}
+	sortCounts(report.Assignees)
+
+	for ticketID, count := range ticketCounts {
+		report.MostPrinted = append(report.MostPrinted, printer.ReportCount{Label: ticketsByID[ticketID].Title, Count: count})
+	}
+	sortCounts(report.MostPrinted)
+	if len(report.MostPrinted) > reportMostPrinted {
+		report.MostPrinted = report.MostPrinted[:reportMostPrinted]
+	}
+
+	// Tickets that were never printed, and the ones that are past their cooldown
+	var overdue []db.Ticket
+	lateBy := make(map[int]time.Duration)
+	for _, ticket := range allTickets {
+		if _, ok := ticketsByID[ticket.ID]; !ok {
+			continue
+		}
+
+		last, printed := lastPrinted[ticket.ID]
+		if !printed {
+			report.NeverPrintedTotal++
+			if len(report.NeverPrinted) < reportNeverPrinted {
+				report.NeverPrinted = append(report.NeverPrinted, ticket.Title)
+			}
+			continue
+		}
+
+		late := now.Sub(last) - time.Duration(ticket.Cooldown)*time.Second
+		if ticket.Cooldown > 0 && late > overdueGrace {
+			overdue = append(overdue, ticket)
+			lateBy[ticket.ID] = late
+		}
+	}
+
+	sort.SliceStable(overdue, func(i, j int) bool {
+		return lateBy[overdue[i].ID] > lateBy[overdue[j].ID]
+	})
+	report.OverdueTotal = len(overdue)
+	for _, ticket := range overdue {
+		if len(report.Overdue) == reportOverdue {
+			break
+		}
+		report.Overdue = append(report.Overdue, printer.ReportOverdue{
+			Title:    ticket.Title,
+			Assignee: ticket.Assignee,
+			Late:     formatLate(lateBy[ticket.ID]),
+		})
+	}
+
+	return report, nil
+}
+
+// sortCounts sorts counts from the highest, then by label
+func sortCounts(counts []printer.ReportCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Label < counts[j].Label
+	})
+}
+
+// formatLate formats how late a ticket is in days, or hours under two days
+func formatLate(late time.Duration) string {
+	if late >= 48*time.Hour {
+		return fmt.Sprintf("%dd", int(late.Hours()/24))
+	}
+	return fmt.Sprintf("%dh", int(late.Hours()))
+}
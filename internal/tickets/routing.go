@@ -0,0 +1,89 @@
+package tickets
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"printy/internal/db"
+)
+
+// PrinterRules picks the printer a job is sent to
+type PrinterRules struct {
+	Default    string            // Printer used when no rule matches, empty for the first printer
+	ByAssignee map[string]string // Lower-cased assignee first name to printer
+	ByTemplate map[string]string // Template name to printer
+	ByPriority map[int]string    // Priority to printer
+}
+
+// LoadPrinterRules reads the printer routing rules from the environment:
+//
+//	ROUTE_DEFAULT=office
+//	ROUTE_BY_ASSIGNEE=Alice=kitchen,Bob=office
+//	ROUTE_BY_TEMPLATE=urgent=office
+//	ROUTE_BY_PRIORITY=Alta=office,3=kitchen
+func LoadPrinterRules() PrinterRules {
+	rules := PrinterRules{
+		Default:    strings.TrimSpace(os.Getenv("ROUTE_DEFAULT")),
+		ByAssignee: make(map[string]string),
+		ByTemplate: make(map[string]string),
+		ByPriority: make(map[int]string),
+	}
+
+	for key, printerName := range parseRuleList(os.Getenv("ROUTE_BY_ASSIGNEE")) {
+		rules.ByAssignee[strings.ToLower(key)] = printerName
+	}
+
+	for key, printerName := range parseRuleList(os.Getenv("ROUTE_BY_TEMPLATE")) {
+		rules.ByTemplate[key] = printerName
+	}
+
+	for key, printerName := range parseRuleList(os.Getenv("ROUTE_BY_PRIORITY")) {
+		// Accept both numeric priorities and the Notion names (Alta, Media, Baja)
+		priority, err := strconv.Atoi(key)
+		if err != nil {
+			priority = ParsePriority(key)
+		}
+		rules.ByPriority[priority] = printerName
+	}
+
+	return rules
+}
+
+// Printers returns every printer named by the rules, to check them against the configured printers
+func (r PrinterRules) Printers() []string {
+	var names []string
+	if r.Default != "" {
+		names = append(names, r.Default)
+	}
+	for _, rules := range []map[string]string{r.ByAssignee, r.ByTemplate} {
+		for _, printerName := range rules {
+			names = append(names, printerName)
+		}
+	}
+	for _, printerName := range r.ByPriority {
+		names = append(names, printerName)
+	}
+	return names
+}
+
+// Select returns the printer for a ticket printed with the given template.
+// The assignee rule wins, then the template rule, then the priority rule, then the default.
+func (r PrinterRules) Select(ticket db.Ticket, template string) string {
+	// Assignee holds comma separated first names, the first one with a rule wins
+	for _, name := range strings.Split(ticket.Assignee, ",") {
+		if printerName, ok := r.ByAssignee[strings.ToLower(strings.TrimSpace(name))]; ok {
+			return printerName
+		}
+	}
+
+	if printerName, ok := r.ByTemplate[template]; ok {
+		return printerName
+	}
+
+	if printerName, ok := r.ByPriority[ticket.Priority]; ok {
+		return printerName
+	}
+
+	return r.Default
+}
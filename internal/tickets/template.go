@@ -0,0 +1,86 @@
+package tickets
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"printy/internal/db"
+)
+
+// TemplateRules picks the template a ticket is printed with
+type TemplateRules struct {
+	Default    string            // Template used when no rule matches, empty for the printer default
+	ByAssignee map[string]string // Lower-cased assignee first name to template
+	ByPriority map[int]string    // Priority to template
+}
+
+// LoadTemplateRules reads the template selection rules from the environment:
+//
+//	TEMPLATE_DEFAULT=sample
+//	TEMPLATE_BY_ASSIGNEE=Alice=fancy,Bob=plain
+//	TEMPLATE_BY_PRIORITY=Alta=urgent,1=compact
+func LoadTemplateRules() TemplateRules {
+	rules := TemplateRules{
+		Default:    strings.TrimSpace(os.Getenv("TEMPLATE_DEFAULT")),
+		ByAssignee: make(map[string]string),
+		ByPriority: make(map[int]string),
+	}
+
+	for key, template := range parseRuleList(os.Getenv("TEMPLATE_BY_ASSIGNEE")) {
+		rules.ByAssignee[strings.ToLower(key)] = template
+	}
+
+	for key, template := range parseRuleList(os.Getenv("TEMPLATE_BY_PRIORITY")) {
+		// Accept both numeric priorities and the Notion names (Alta, Media, Baja)
+		priority, err := strconv.Atoi(key)
+		if err != nil {
+			priority = ParsePriority(key)
+		}
+		rules.ByPriority[priority] = template
+	}
+
+	return rules
+}
+
+// Select returns the template for a ticket. The template set in Notion wins,
+// then the assignee rule, then the priority rule, then the default.
+func (r TemplateRules) Select(ticket db.Ticket) string {
+	if ticket.Template != "" {
+		return ticket.Template
+	}
+
+	// Assignee holds comma separated first names, the first one with a rule wins
+	for _, name := range strings.Split(ticket.Assignee, ",") {
+		if template, ok := r.ByAssignee[strings.ToLower(strings.TrimSpace(name))]; ok {
+			return template
+		}
+	}
+
+	if template, ok := r.ByPriority[ticket.Priority]; ok {
+		return template
+	}
+
+	return r.Default
+}
+
+// parseRuleList parses "key=value,key=value" lists, skipping malformed entries
+func parseRuleList(value string) map[string]string {
+	rules := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, template, ok := strings.Cut(entry, "=")
+		key, template = strings.TrimSpace(key), strings.TrimSpace(template)
+		if !ok || key == "" || template == "" {
+			log.Printf("⚠️  Warning: Ignoring invalid rule %q", entry)
+			continue
+		}
+		rules[key] = template
+	}
+	return rules
+}
@@ -14,6 +14,14 @@ func main() {
 	port := flag.String("port", "8080", "Port to run the server on")
 	flag.Parse()
 
+	// printy migrate [status|up] manages the database schema without starting the server
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(flag.Args()[1:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	// Create and start server
 	s, err := server.New(*port)
 	if err != nil {
@@ -23,6 +31,12 @@ func main() {
 
 	fmt.Printf("🚀 Starting Printy HTTP Server on port %s\n", *port)
 	fmt.Printf("📋 Set PRINTER_NAME environment variable to specify printer\n")
+	fmt.Printf("🔌 Set PRINTER_TRANSPORT (cups, ipp, tcp, device, file) and PRINTER_ADDRESS to choose how jobs reach it\n")
+	fmt.Printf("🗂️  Set PRINTERS_FILE to register several printers, and ROUTE_BY_* to choose between them\n")
+	fmt.Printf("🧾 Set PRINTER_PROFILE (pos80, pos58) to match the paper width and features of the printer\n")
+	fmt.Printf("📡 Set RAW_LISTEN_ADDR (e.g. :9100) to accept raw ESC/POS jobs from other applications\n")
+	fmt.Printf("🔖 Set SCANNER_INPUT (a device, named pipe or - for stdin) to mark scanned tickets as done\n")
+	fmt.Printf("🔗 Set PUBLIC_URL (e.g. http://printy.local:8080) to print a QR code that marks tickets as done\n")
 	fmt.Printf("📊 Set DB_PATH environment variable to specify database location\n")
 	fmt.Printf("🌐 Server will be available at: http://localhost:%s\n", *port)
 
@@ -30,6 +44,11 @@ func main() {
 	scheduler := cron.NewScheduler(fmt.Sprintf("http://localhost:%s", *port))
 	scheduler.StartDailyPrintJob()
 	fmt.Printf("⏰ Daily print job scheduled for 8:00 AM Montreal time\n")
+	if scheduler.StartWeeklyReportJob() {
+		fmt.Printf("📈 Weekly report scheduled, POST /print-report prints it on demand\n")
+	} else {
+		fmt.Printf("📈 Set WEEKLY_REPORT_DAY (e.g. Sunday) to print the weekly report, or POST /print-report\n")
+	}
 
 	// Start server (this blocks)
 	if err := s.Start(); err != nil {
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+
+	"printy/internal/db"
+)
+
+// runMigrate runs the migrate command: "status" lists the migrations and whether they were
+// applied, "up" applies the pending ones
+func runMigrate(args []string) error {
+	command := "status"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	if len(args) > 1 || (command != "status" && command != "up") {
+		return fmt.Errorf("usage: printy migrate [status|up]")
+	}
+
+	dbPath, err := db.Path()
+	if err != nil {
+		return err
+	}
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if command == "up" {
+		applied, err := database.Migrate()
+		for _, migration := range applied {
+			fmt.Printf("♻️  Applied %s\n", migration)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Printf("✅ %s is up to date\n", dbPath)
+		}
+		return nil
+	}
+
+	version, err := database.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	statuses, err := database.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("📊 %s is at schema version %d\n", dbPath, version)
+	for _, status := range statuses {
+		if status.Applied {
+			fmt.Printf("✅ %s applied %s\n", status.Migration, status.AppliedAt.Local().Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Printf("⏳ %s pending\n", status.Migration)
+		}
+	}
+	if latest := statuses[len(statuses)-1].Version; version > latest {
+		fmt.Printf("⚠️  The database is newer than this build, which knows migrations up to %d\n", latest)
+	}
+	return nil
+}